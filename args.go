@@ -0,0 +1,103 @@
+package sqlbind
+
+import (
+	"database/sql"
+	"net/url"
+	"reflect"
+	"sort"
+)
+
+// ArgSource is a custom source of named parameters, usable as an arg of Named or in Args
+type ArgSource interface {
+	// Names returns the names of the parameters, used by ::names, ::values, ::name=::value and ::match
+	Names() []string
+	// Lookup returns the value of a parameter, and whether it was found
+	Lookup(name string) (interface{}, bool)
+}
+
+// Values returns an ArgSource for url.Values (e.g. query string parameters).
+// Parameters having a single value are bound as a string, parameters having several values as a []string.
+//
+//  sqlbind.Named("SELECT * FROM example WHERE name=:name AND id IN(:id)", sqlbind.Values(r.URL.Query()))
+func Values(v url.Values) ArgSource {
+	return urlValues(v)
+}
+
+type urlValues url.Values
+
+func (v urlValues) Names() []string {
+	return sortedKeys(reflect.ValueOf(v))
+}
+
+func (v urlValues) Lookup(name string) (interface{}, bool) {
+	vals, found := v[name]
+	if !found || len(vals) == 0 {
+		return nil, found
+	}
+	if len(vals) == 1 {
+		return vals[0], true
+	}
+	return vals, true
+}
+
+// NamedArgs returns an ArgSource for a list of sql.NamedArg
+//
+//  sqlbind.Named("SELECT * FROM example WHERE name=:name", sqlbind.NamedArgs(sql.Named("name", "foo")))
+func NamedArgs(args ...sql.NamedArg) ArgSource {
+	return namedArgs(args)
+}
+
+type namedArgs []sql.NamedArg
+
+func (a namedArgs) Names() []string {
+	names := make(sort.StringSlice, 0, len(a))
+	for _, arg := range a {
+		names = append(names, arg.Name)
+	}
+	sort.Sort(&names)
+	return []string(names)
+}
+
+func (a namedArgs) Lookup(name string) (interface{}, bool) {
+	for _, arg := range a {
+		if arg.Name == name {
+			return arg.Value, true
+		}
+	}
+	return nil, false
+}
+
+// argSource returns arg as an ArgSource, adapting []sql.NamedArg and url.Values
+func argSource(arg interface{}) (ArgSource, bool) {
+	switch a := arg.(type) {
+	case ArgSource:
+		return a, true
+	case []sql.NamedArg:
+		return namedArgs(a), true
+	case url.Values:
+		return urlValues(a), true
+	}
+	return nil, false
+}
+
+// isStringMap returns true for maps having string keys (e.g. map[string]string)
+func isStringMap(v reflect.Value) bool {
+	return v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String
+}
+
+func sortedKeys(m reflect.Value) []string {
+	names := make(sort.StringSlice, 0, m.Len())
+	for _, k := range m.MapKeys() {
+		names = append(names, k.String())
+	}
+	sort.Sort(&names)
+	return []string(names)
+}
+
+func mapValue(m reflect.Value, key string) (interface{}, bool) {
+	v := m.MapIndex(reflect.ValueOf(key).Convert(m.Type().Key()))
+	if !v.IsValid() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
@@ -0,0 +1,195 @@
+package sqlbind
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Encoder converts the value of a struct field to a database value
+type Encoder func(v interface{}) (interface{}, error)
+
+// Decoder converts a database value (never nil) to a struct field, dst being a pointer to the field
+type Decoder func(src interface{}, dst interface{}) error
+
+type codec struct {
+	enc Encoder
+	dec Decoder
+}
+
+var (
+	codecs = map[string]codec{
+		"json":   {enc: encodeJSON, dec: decodeJSON},
+		"text":   {enc: encodeText, dec: decodeText},
+		"string": {enc: encodeString, dec: decodeString},
+	}
+
+	ErrUnsupportedType = errors.New("Unsupported type")
+)
+
+// RegisterCodec registers a codec, used by fields having its name as a tag option. RegisterCodec is not safe. Do not use concurently.
+//
+// json, text (using encoding.TextMarshaler/TextUnmarshaler) and string (numbers and booleans stored as strings) codecs are available by default :
+//   type Example struct {
+//   	Meta map[string]string `db:"meta,json"`
+//   	IP   net.IP            `db:"ip,text"`
+//   	Age  int               `db:"age,string"`
+//   }
+//
+// enc or dec can be nil, the codec is then only used by Named (enc) or by Scan (dec).
+func RegisterCodec(name string, enc Encoder, dec Decoder) {
+	codecs[name] = codec{enc: enc, dec: dec}
+}
+
+// codec returns the first registered codec of the tag options
+func (o tagOptions) codec() (codec, bool) {
+	if o == "" {
+		return codec{}, false
+	}
+	for _, opt := range strings.Split(string(o), ",") {
+		if c, found := codecs[opt]; found {
+			return c, true
+		}
+	}
+	return codec{}, false
+}
+
+// encode encodes the value of a field using its codec. nil pointers, maps and slices are encoded as nil.
+func (c codec) encode(fv reflect.Value) (interface{}, error) {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if fv.IsNil() {
+			return nil, nil
+		}
+	}
+	return c.enc(fv.Interface())
+}
+
+// codecScanner is a sql.Scanner decoding values to a field using a codec
+type codecScanner struct {
+	dec Decoder
+	dst interface{}
+}
+
+func (c codecScanner) Scan(src interface{}) error {
+	if src == nil {
+		v := reflect.ValueOf(c.dst).Elem()
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	return c.dec(src, c.dst)
+}
+
+func encodeJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func decodeJSON(src interface{}, dst interface{}) error {
+	b, err := srcBytes(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+func encodeText(v interface{}) (interface{}, error) {
+	m, ok := v.(encoding.TextMarshaler)
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+	b, err := m.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func decodeText(src interface{}, dst interface{}) error {
+	u, ok := dst.(encoding.TextUnmarshaler)
+	if !ok {
+		return ErrUnsupportedType
+	}
+	b, err := srcBytes(src)
+	if err != nil {
+		return err
+	}
+	return u.UnmarshalText(b)
+}
+
+func encodeString(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.String:
+		return rv.String(), nil
+	}
+	return nil, ErrUnsupportedType
+}
+
+func decodeString(src interface{}, dst interface{}) error {
+	b, err := srcBytes(src)
+	if err != nil {
+		return err
+	}
+	s := string(b)
+	v := reflect.ValueOf(dst).Elem()
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.String:
+		v.SetString(s)
+	default:
+		return ErrUnsupportedType
+	}
+	return nil
+}
+
+// srcBytes returns the bytes of a database value, converting other types using their default format
+func srcBytes(src interface{}) ([]byte, error) {
+	switch v := src.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case int64, float64, bool:
+		return []byte(fmt.Sprint(v)), nil
+	}
+	return nil, ErrUnsupportedType
+}
@@ -0,0 +1,52 @@
+package sqlbind
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RegisterConverter registers a converter for the default binder. See SQLBinder.RegisterConverter.
+func RegisterConverter(t reflect.Type, toDB Encoder, fromDB Decoder) {
+	defaultBinder.RegisterConverter(t, toDB, fromDB)
+}
+
+// RegisterConverter registers functions converting values of type t to database values in Named, and database values
+// to fields of type t in Scan. It is useful for types not implementing driver.Valuer/sql.Scanner that cannot be modified.
+// Codecs defined in tags take precedence over converters. RegisterConverter is not safe. Do not use concurently.
+//
+//   s.RegisterConverter(reflect.TypeOf(uuid.UUID{}), func(v interface{}) (interface{}, error) {
+//   	return v.(uuid.UUID).String(), nil
+//   }, func(src interface{}, dst interface{}) error {
+//   	u, err := uuid.ParseBytes(src.([]byte))
+//   	*dst.(*uuid.UUID) = u
+//   	return err
+//   })
+func (s *SQLBinder) RegisterConverter(t reflect.Type, toDB Encoder, fromDB Decoder) {
+	if s.converters == nil {
+		s.converters = map[reflect.Type]codec{}
+	}
+	s.converters[t] = codec{enc: toDB, dec: fromDB}
+}
+
+// convertArgs converts args having a registered converter
+func (s *SQLBinder) convertArgs(args []interface{}) error {
+	if len(s.converters) == 0 {
+		return nil
+	}
+	for i, arg := range args {
+		if c, found := s.converters[reflect.TypeOf(arg)]; found && c.enc != nil {
+			val, err := c.enc(arg)
+			if err != nil {
+				return fmt.Errorf("Unable to convert %T : %s", arg, err)
+			}
+			args[i] = val
+		}
+	}
+	return nil
+}
+
+// converter returns the converter decoding values to fields of type t
+func (s *SQLBinder) converter(t reflect.Type) (Decoder, bool) {
+	c, found := s.converters[t]
+	return c.dec, found && c.dec != nil
+}
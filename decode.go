@@ -1,6 +1,9 @@
 package sqlbind
 
-import "unicode"
+import (
+	"strings"
+	"unicode"
+)
 
 const (
 	typeSQL = iota
@@ -9,12 +12,20 @@ const (
 	typeNames
 	typeValues
 	typeNameValue
+	typeMatch
+	typePK
+	typeSectionStart
+	typeSectionEnd
+	typeFragment
 	typeSeparator
 )
 
 type part struct {
 	t    int
 	data string
+	// def is the default value of a variable ({name:default}), hasDef is true if a default value is set
+	def    string
+	hasDef bool
 }
 
 type decoded struct {
@@ -31,18 +42,39 @@ type decodeState struct {
 	step func(*decodeState, string) int
 	str  []byte
 	err  error
+	// depth is the number of open conditional sections
+	depth int
+	// brackets is the number of open square brackets that are not section delimiters (e.g. arrays)
+	brackets int
+	// quoted is true inside single-quoted literals, comment is the type of the current comment
+	quoted  bool
+	comment int
+	// skip is the number of characters to be skipped by literal
+	skip int
+	// text is true after a [[ that is not a section delimiter, placeholder is true if a placeholder follows it
+	text        bool
+	placeholder bool
+	// prev and prevType are the previous character and its type
+	prev     byte
+	prevType int
 }
 
-func decode(str string) *decoded {
+const (
+	noComment = iota
+	lineComment
+	blockComment
+)
+
+func decode(str string) (*decoded, error) {
 	c := &decoded{parts: []part{}, types: map[int]struct{}{}}
 	d := newDecodeState()
 	cur := typeSQL
 	start := 0
 	for i := range str {
 		next := d.step(d, str[i:])
+		d.prev, d.prevType = str[i], next
 		if next != cur && cur != typeSeparator && i > 0 {
-			c.types[cur] = struct{}{}
-			c.parts = append(c.parts, part{t: cur, data: str[start:i]})
+			c.append(cur, str[start:i])
 			start = i
 		}
 		if cur == typeSeparator {
@@ -51,10 +83,42 @@ func decode(str string) *decoded {
 		cur = next
 	}
 	if len(str) > start && cur != typeSeparator {
-		c.types[cur] = struct{}{}
-		c.parts = append(c.parts, part{t: cur, data: str[start:]})
+		c.append(cur, str[start:])
+	}
+	return c, d.err
+}
+
+// append appends a part, splitting consecutive section delimiters ([[[[ or ]]]]) into one part each
+func (d *decoded) append(t int, data string) {
+	if t == typeSectionStart || t == typeSectionEnd {
+		d.types[t] = struct{}{}
+		for i := 0; i < len(data)/2; i++ {
+			d.parts = append(d.parts, part{t: t, data: data[2*i : 2*i+2]})
+		}
+		return
+	}
+	p := newPart(t, data)
+	d.types[p.t] = struct{}{}
+	d.parts = append(d.parts, p)
+}
+
+// newPart creates a part, splitting variables between their name and their default value.
+// Optional variables ({name?}) default to an empty string, and {>name} includes a fragment.
+func newPart(t int, data string) part {
+	if t != typeVariable {
+		return part{t: t, data: data}
+	}
+	if strings.HasPrefix(data, ">") {
+		return part{t: typeFragment, data: data[1:]}
+	}
+	p := part{t: t, data: data}
+	if idx := strings.IndexByte(data, ':'); idx != -1 {
+		p.data, p.def, p.hasDef = data[:idx], data[idx+1:], true
+	}
+	if strings.HasSuffix(p.data, "?") {
+		p.data, p.hasDef = p.data[:len(p.data)-1], true
 	}
-	return c
+	return p
 }
 
 func newDecodeState() *decodeState {
@@ -62,6 +126,7 @@ func newDecodeState() *decodeState {
 }
 
 func scanSQL(d *decodeState, str string) int {
+	literal := d.literal(str)
 	switch str[0] {
 	case ':':
 		d.step = scanColon
@@ -72,10 +137,94 @@ func scanSQL(d *decodeState, str string) int {
 	case '"':
 		d.step = scanString
 		return typeSQL
+	case '[':
+		if !literal && d.isSectionStart(str) {
+			d.depth++
+			d.step = skipN(2, typeSectionStart)
+			return d.step(d, str)
+		}
+		if len(str) >= 2 && str[1] == '[' && d.isDelimiterPosition() {
+			d.text, d.placeholder = true, false
+		}
+		if !literal {
+			d.brackets++
+		}
+	case ']':
+		if len(str) >= 2 && str[1] == ']' && d.text {
+			// a [[ ... ]] pair containing placeholders is not a section, e.g. [[AND a=:a]]
+			if d.placeholder && d.err == nil {
+				d.err = ErrInvalidSection
+			}
+			d.text = false
+		}
+		if literal {
+			break
+		}
+		if d.brackets > 0 {
+			d.brackets--
+		} else if len(str) >= 2 && str[1] == ']' && d.depth > 0 {
+			d.depth--
+			d.step = skipN(2, typeSectionEnd)
+			return d.step(d, str)
+		}
 	}
 	return typeSQL
 }
 
+// literal updates the state of quoted literals and comments, and returns true if str[0] is part of a literal or a comment
+func (d *decodeState) literal(str string) bool {
+	if d.skip > 0 {
+		d.skip--
+		return true
+	}
+	switch {
+	case d.comment == lineComment:
+		if str[0] == '\n' {
+			d.comment = noComment
+		}
+		return true
+	case d.comment == blockComment:
+		if strings.HasPrefix(str, "*/") {
+			d.comment = noComment
+			d.skip = 1
+		}
+		return true
+	case d.quoted:
+		switch str[0] {
+		case '\\':
+			d.skip = 1
+		case '\'':
+			d.quoted = false
+		}
+		return true
+	case str[0] == '\'':
+		d.quoted = true
+		return true
+	case strings.HasPrefix(str, "--"):
+		d.comment = lineComment
+		return true
+	case strings.HasPrefix(str, "/*"):
+		d.comment = blockComment
+		d.skip = 1
+		return true
+	}
+	return false
+}
+
+// isSectionStart returns true if str starts with a section delimiter : [[ followed by a whitespace, and preceded by
+// a whitespace, an opening parenthesis or another section. Other [[ (e.g. ARRAY[[1,2],[3,4]]) are SQL.
+func (d *decodeState) isSectionStart(str string) bool {
+	if len(str) < 3 || str[1] != '[' || !unicode.IsSpace(rune(str[2])) {
+		return false
+	}
+	return d.isDelimiterPosition()
+}
+
+// isDelimiterPosition returns true if a section delimiter can start at the current position
+func (d *decodeState) isDelimiterPosition() bool {
+	return d.prev == 0 || d.prev == '(' || unicode.IsSpace(rune(d.prev)) || d.prevType == typeSectionStart || d.prevType == typeSectionEnd
+}
+
 func scanString(d *decodeState, str string) int {
 	switch str[0] {
 	case '"':
@@ -90,6 +239,9 @@ func scanColon(d *decodeState, str string) int {
 		return scanDoubleColon(d, str)
 	}
 	d.step = scanPlaceholder
+	if d.text {
+		d.placeholder = true
+	}
 	return d.step(d, str)
 }
 
@@ -99,8 +251,7 @@ func scanPlaceholder(d *decodeState, str string) int {
 	// TODO : try DecodeRuneInString to enable UTF8 placeholder
 	if !unicode.IsOneOf(allowedPlaceholderRunes, rune(str[0])) && str[0] != '_' {
 		d.step = scanSQL
-		return typeSQL
-
+		return d.step(d, str)
 	}
 	return typePlaceholder
 }
@@ -113,6 +264,10 @@ func scanDoubleColon(d *decodeState, str string) int {
 		d.step = skipN(6, typeNames)
 	case len(str) >= 7 && str[:7] == ":values":
 		d.step = skipN(7, typeValues)
+	case len(str) >= 6 && str[:6] == ":match":
+		d.step = skipN(6, typeMatch)
+	case len(str) >= 3 && str[:3] == ":pk":
+		d.step = skipN(3, typePK)
 	default:
 		d.step = scanSQL
 		return typeSQL
@@ -136,7 +291,7 @@ func skipN(n int, t int) func(d *decodeState, str string) int {
 			return t
 		} else {
 			d.step = scanSQL
-			return typeSQL
+			return d.step(d, str)
 		}
 	}
 }
@@ -2,67 +2,136 @@ package sqlbind
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
+	"unicode"
 )
 
 var (
 	fieldMap = struct {
-		index map[reflect.Type]map[string][]int
-		names map[reflect.Type][]string
+		index map[typeKey]map[string]fieldInfo
+		names map[typeKey][]string
 	}{
-		index: map[reflect.Type]map[string][]int{},
-		names: map[reflect.Type][]string{},
+		index: map[typeKey]map[string]fieldInfo{},
+		names: map[typeKey][]string{},
 	}
 
+	// SnakeCase maps CreatedAt to created_at and UserID to user_id
+	SnakeCase NameMapper = snakeCase
+	// LowerCase maps CreatedAt to createdat
+	LowerCase NameMapper = strings.ToLower
+
 	ErrNoPointerToField = errors.New("Cannot get pointer to field")
 	ErrFieldNotFound    = errors.New("Field not found")
+	ErrNoPrimaryKey     = errors.New("No field tagged pk")
 )
 
-// Register registers a type to be used Register is not safe. Do not use concurently.
-func Register(l ...interface{}) struct{} {
+// NameMapper maps the name of a struct field without a db tag name to a column name
+type NameMapper func(string) string
+
+// nameMapper wraps a NameMapper, its address being used to cache struct mappings per mapper
+type nameMapper struct {
+	fn NameMapper
+}
+
+type typeKey struct {
+	t      reflect.Type
+	mapper *nameMapper
+}
+
+type fieldInfo struct {
+	index []int
+	opts  tagOptions
+}
+
+// Register registers a type to be used by the default binder. Register is not safe. Do not use concurently.
+//
+// An error is returned if a column name is ambiguous, i.e. used by several fields at the same depth.
+func Register(l ...interface{}) error {
+	return defaultBinder.Register(l...)
+}
+
+// Register registers a type to be used by the binder, using its name mapper. Register is not safe. Do not use concurently.
+//
+// An error is returned if a column name is ambiguous, i.e. used by several fields at the same depth.
+func (s *SQLBinder) Register(l ...interface{}) error {
+	var err error
 	for _, i := range l {
 		t := reflect.Indirect(reflect.ValueOf(i)).Type()
 
-		is := map[string][]int{}
-		buildIndexes(t, []int{}, is)
-		fieldMap.index[t] = is
+		is, ierr := s.buildIndexes(t)
+		if ierr != nil {
+			if err == nil {
+				err = ierr
+			}
+			continue
+		}
+		fieldMap.index[s.key(t)] = is
 
-		fieldMap.names[t] = buildNames(t)
+		fieldMap.names[s.key(t)] = namesFromIndexes(is)
 	}
 
-	return struct{}{}
+	return err
 }
 
-func names(arg interface{}) []string {
+func (s *SQLBinder) key(t reflect.Type) typeKey {
+	return typeKey{t: t, mapper: s.mapper}
+}
+
+// names returns the names of the parameters of arg, including missing struct fields (see present),
+// or ErrUnsupportedFormat if arg is not a supported type
+func (s *SQLBinder) names(arg interface{}) ([]string, error) {
 	if arg == nil {
-		return []string{}
+		return []string{}, nil
 	}
-	if m, ok := arg.(map[string]interface{}); ok {
+	if src, ok := argSource(arg); ok {
+		return src.Names(), nil
+	} else if m, ok := arg.(map[string]interface{}); ok {
 		names := make(sort.StringSlice, 0, len(m))
 		for name := range m {
 			names = append(names, name)
 		}
 		sort.Sort(&names)
-		return []string(names)
-	} else if v := reflect.Indirect(reflect.ValueOf(arg)); v.Type().Kind() == reflect.Struct {
-		if names, found := fieldMap.names[v.Type()]; found {
-			return filterMissing(names, v)
+		return []string(names), nil
+	} else if v := reflect.Indirect(reflect.ValueOf(arg)); isStringMap(v) {
+		return sortedKeys(v), nil
+	} else if v.IsValid() && v.Type().Kind() == reflect.Struct {
+		if names, found := fieldMap.names[s.key(v.Type())]; found {
+			return names, nil
+		}
+		is, err := s.buildIndexes(v.Type())
+		if err != nil {
+			return nil, err
 		}
-		return filterMissing(buildNames(v.Type()), v)
+		return namesFromIndexes(is), nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// present removes missing struct fields from names
+func (s *SQLBinder) present(names []string, arg interface{}) []string {
+	if v := reflect.Indirect(reflect.ValueOf(arg)); v.IsValid() && v.Type().Kind() == reflect.Struct {
+		return s.filterMissing(names, v)
 	}
-	return []string{}
+	return names
 }
 
 type Missinger interface {
 	Missing() bool
 }
 
-func filterMissing(names []string, v reflect.Value) []string {
+// IsZeroer is implemented by types that define their own zero value (e.g. time.Time), used by the omitempty tag option.
+type IsZeroer interface {
+	IsZero() bool
+}
+
+func (s *SQLBinder) filterMissing(names []string, v reflect.Value) []string {
+	is := s.indexes(v.Type())
 	n := make([]string, 0, len(names))
 	for _, name := range names {
-		fv, ok := field(name, v)
+		fv, ok := s.field(name, v)
 		if !ok || !fv.CanInterface() {
 			continue
 		}
@@ -72,43 +141,67 @@ func filterMissing(names []string, v reflect.Value) []string {
 		if fv.Kind() == reflect.Ptr && fv.IsNil() {
 			continue
 		}
+		if r, ok := fv.Interface().(Raw); ok && r == "" {
+			continue
+		}
+		if opts := is[name].opts; (opts.contains("omitempty") || opts.contains("omitzero")) && isZero(fv) {
+			continue
+		}
 		n = append(n, name)
 	}
 	return n
 }
 
-func value(key string, arg interface{}, args ...interface{}) (interface{}, bool) {
-	nilfound := false
-	if m, ok := arg.(map[string]interface{}); ok {
-		if val, found := m[key]; found {
-			if val == nil {
-				nilfound = true
-			} else {
-				return val, true
-			}
+func isZero(v reflect.Value) bool {
+	if i, ok := v.Interface().(IsZeroer); ok {
+		return i.IsZero()
+	}
+	return v.IsZero()
+}
+
+// value returns the value of key, looking first in arg then in args. A nil value is only returned if no non-nil value was found.
+func (s *SQLBinder) value(key string, arg interface{}, args ...interface{}) (interface{}, bool, error) {
+	val, nilfound, err := s.lookup(key, arg)
+	if val != nil || err != nil {
+		return val, true, err
+	}
+	for _, arg := range args {
+		if val, found, err := s.value(key, arg); found || err != nil {
+			return val, found, err
 		}
-	} else if v := reflect.Indirect(reflect.ValueOf(arg)); v.Type().Kind() == reflect.Struct {
-		if fv, found := field(key, v); found && fv.CanInterface() {
+	}
+	return nil, nilfound, nil
+}
+
+// lookup returns the value of key in arg, encoded if the field has a codec. Missing values (see Missinger) are returned as nil.
+func (s *SQLBinder) lookup(key string, arg interface{}) (interface{}, bool, error) {
+	if src, ok := argSource(arg); ok {
+		val, found := src.Lookup(key)
+		return val, found, nil
+	} else if m, ok := arg.(map[string]interface{}); ok {
+		val, found := m[key]
+		return val, found, nil
+	} else if v := reflect.Indirect(reflect.ValueOf(arg)); isStringMap(v) {
+		val, found := mapValue(v, key)
+		return val, found, nil
+	} else if v.IsValid() && v.Type().Kind() == reflect.Struct {
+		if fv, found := s.field(key, v); found && fv.CanInterface() {
 			if i, ok := fv.Interface().(Missinger); ok && i.Missing() {
-				nilfound = true
-			} else if !fv.IsValid() || fv.Interface() == nil {
-				nilfound = true
-			} else {
-				return fv.Interface(), true
+				return nil, true, nil
 			}
+			if c, ok := s.indexes(v.Type())[key].opts.codec(); ok && c.enc != nil {
+				val, err := c.encode(fv)
+				return val, true, err
+			}
+			return fv.Interface(), true, nil
 		}
 	}
-	for _, arg := range args {
-		if val, found := value(key, arg); found {
-			return val, found
-		}
-	}
-	return nil, nilfound
+	return nil, false, nil
 }
 
-func pointerto(key string, arg interface{}) (interface{}, error) {
+func (s *SQLBinder) pointerto(key string, arg interface{}) (interface{}, error) {
 	if v := reflect.Indirect(reflect.ValueOf(arg)); v.Type().Kind() == reflect.Struct {
-		if fv, found := field(key, v); found {
+		if fv, found := s.field(key, v); found {
 			if !fv.CanAddr() {
 				return nil, ErrNoPointerToField
 			}
@@ -118,13 +211,59 @@ func pointerto(key string, arg interface{}) (interface{}, error) {
 	return nil, ErrFieldNotFound
 }
 
-func field(key string, v reflect.Value) (reflect.Value, bool) {
-	is, found := fieldMap.index[v.Type()]
-	if !found {
-		is = map[string][]int{}
-		buildIndexes(v.Type(), []int{}, is)
+// indexes returns the fields of t. Ambiguous names are not included.
+func (s *SQLBinder) indexes(t reflect.Type) map[string]fieldInfo {
+	is, _ := s.typeIndexes(t)
+	return is
+}
+
+// typeIndexes returns the fields of t, and an error if t has ambiguous names.
+func (s *SQLBinder) typeIndexes(t reflect.Type) (map[string]fieldInfo, error) {
+	if is, found := fieldMap.index[s.key(t)]; found {
+		return is, nil
+	}
+	return s.buildIndexes(t)
+}
+
+// primaryKeys returns the names of the fields tagged pk, in declaration order
+func (s *SQLBinder) primaryKeys(t reflect.Type) []string {
+	is := s.indexes(t)
+	pks := []string{}
+	for name, f := range is {
+		if f.opts.contains("pk") {
+			pks = append(pks, name)
+		}
 	}
-	if idxs, found := is[key]; found {
+	sortByIndex(pks, is)
+	return pks
+}
+
+// declared returns the names of all fields, in declaration order
+func (s *SQLBinder) declared(t reflect.Type) []string {
+	is := s.indexes(t)
+	names := make([]string, 0, len(is))
+	for name := range is {
+		names = append(names, name)
+	}
+	sortByIndex(names, is)
+	return names
+}
+
+func sortByIndex(names []string, is map[string]fieldInfo) {
+	sort.Slice(names, func(i, j int) bool {
+		a, b := is[names[i]].index, is[names[j]].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+func (s *SQLBinder) field(key string, v reflect.Value) (reflect.Value, bool) {
+	if f, found := s.indexes(v.Type())[key]; found {
+		idxs := f.index
 		for i, idx := range idxs {
 			v = v.FieldByIndex([]int{idx})
 			if i != len(idxs)-1 {
@@ -140,39 +279,69 @@ func field(key string, v reflect.Value) (reflect.Value, bool) {
 	return reflect.Value{}, false
 }
 
-func buildNames(t reflect.Type) []string {
-	names := make(sort.StringSlice, 0, t.NumField())
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		ft := f.Type
-		tag := f.Tag.Get("db")
-		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+func namesFromIndexes(is map[string]fieldInfo) []string {
+	names := make(sort.StringSlice, 0, len(is))
+	for name, f := range is {
+		if f.opts.contains("ro") {
 			continue
 		}
-		if tag == "" {
-			if ft.Kind() == reflect.Ptr {
-				ft = ft.Elem()
-			}
-			if ft.Kind() == reflect.Struct {
-				add := buildNames(ft)
-				names = append(names, add...)
-				continue
+		names = append(names, name)
+	}
+	sort.Sort(&names)
+	return []string(names)
+}
+
+// buildIndexes maps names to fields. When several fields have the same name, the shallowest one is used,
+// and an error is returned if there are several shallowest fields.
+func (s *SQLBinder) buildIndexes(t reflect.Type) (map[string]fieldInfo, error) {
+	candidates := map[string][]fieldInfo{}
+	s.appendIndexes(t, []int{}, "", candidates)
+	m := make(map[string]fieldInfo, len(candidates))
+	ambiguous := []string{}
+	for name, fs := range candidates {
+		shallowest := []fieldInfo{fs[0]}
+		for _, f := range fs[1:] {
+			switch {
+			case len(f.index) < len(shallowest[0].index):
+				shallowest = []fieldInfo{f}
+			case len(f.index) == len(shallowest[0].index):
+				shallowest = append(shallowest, f)
 			}
 		}
-		name, opt := parseTag(tag)
-		if opt == "ro" {
+		if len(shallowest) > 1 {
+			paths := make([]string, len(shallowest))
+			for i, f := range shallowest {
+				paths[i] = fieldPath(t, f.index)
+			}
+			ambiguous = append(ambiguous, fmt.Sprintf("%s (%s)", name, strings.Join(paths, ", ")))
 			continue
 		}
-		if name == "" {
-			name = f.Name
+		m[name] = shallowest[0]
+	}
+	if len(ambiguous) > 0 {
+		sort.Strings(ambiguous)
+		return m, fmt.Errorf("Ambiguous column names in %s : %s", t, strings.Join(ambiguous, ", "))
+	}
+	return m, nil
+}
+
+// fieldPath returns the Go path (e.g. Address.ID) of the field at index
+func fieldPath(t reflect.Type, index []int) string {
+	path := make([]string, len(index))
+	for i, idx := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
 		}
-		names = append(names, name)
+		f := t.Field(idx)
+		path[i] = f.Name
+		t = f.Type
 	}
-	sort.Sort(&names)
-	return []string(names)
+	return strings.Join(path, ".")
 }
 
-func buildIndexes(t reflect.Type, idx []int, m map[string][]int) {
+// appendIndexes adds the fields of t to the candidates of m. Untagged struct fields, and struct fields tagged with a prefix option
+// (e.g. `db:",prefix=billing_"`), are inlined, prefix being prepended to the names of their fields.
+func (s *SQLBinder) appendIndexes(t reflect.Type, idx []int, prefix string, m map[string][]fieldInfo) {
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i)
 		ft := f.Type
@@ -185,26 +354,91 @@ func buildIndexes(t reflect.Type, idx []int, m map[string][]int) {
 		copy(nidx, idx)
 		nidx = append(nidx, i)
 
-		if tag == "" {
+		name, opts := parseTag(tag)
+		if fprefix, ok := opts.value("prefix"); tag == "" || (name == "" && ok) {
 			if ft.Kind() == reflect.Ptr {
 				ft = ft.Elem()
 			}
-			if ft.Kind() == reflect.Struct {
-				buildIndexes(ft, nidx, m)
+			// embedded structs are always inlined, even if they implement driver.Valuer or sql.Scanner
+			if ft.Kind() == reflect.Struct && (f.Anonymous || !isColumnType(ft)) {
+				s.appendIndexes(ft, nidx, prefix+fprefix, m)
 				continue
 			}
 		}
-		name, _ := parseTag(tag)
 		if name == "" {
-			name = f.Name
+			name = s.mapName(f.Name)
+		}
+		m[prefix+name] = append(m[prefix+name], fieldInfo{index: nidx, opts: opts})
+	}
+}
+
+// isColumnType returns true for struct types stored in a single column (time.Time, driver.Valuer or sql.Scanner),
+// which are not inlined
+func isColumnType(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return t == timeType || t.Implements(valuerType) || pt.Implements(valuerType) || pt.Implements(scannerType)
+}
+
+func (s *SQLBinder) mapName(name string) string {
+	if s.mapper == nil {
+		return name
+	}
+	return s.mapper.fn(name)
+}
+
+func snakeCase(name string) string {
+	runes := []rune(name)
+	buf := make([]rune, 0, len(runes)+4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				buf = append(buf, '_')
+			}
+			r = unicode.ToLower(r)
 		}
-		m[name] = nidx
+		buf = append(buf, r)
 	}
+	return string(buf)
 }
 
-func parseTag(tag string) (string, string) {
+// tagOptions is the comma-separated list of options following the name in a db tag (e.g. "ro,omitempty")
+type tagOptions string
+
+func parseTag(tag string) (string, tagOptions) {
 	if idx := strings.Index(tag, ","); idx != -1 {
-		return tag[:idx], tag[idx+1:]
+		return tag[:idx], tagOptions(tag[idx+1:])
 	}
 	return tag, ""
 }
+
+func (o tagOptions) contains(opt string) bool {
+	s := string(o)
+	for s != "" {
+		var next string
+		if idx := strings.Index(s, ","); idx != -1 {
+			s, next = s[:idx], s[idx+1:]
+		}
+		if s == opt {
+			return true
+		}
+		s = next
+	}
+	return false
+}
+
+// value returns the value of a key=value option
+func (o tagOptions) value(key string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var next string
+		if idx := strings.Index(s, ","); idx != -1 {
+			s, next = s[:idx], s[idx+1:]
+		}
+		if strings.HasPrefix(s, key+"=") {
+			return s[len(key)+1:], true
+		}
+		s = next
+	}
+	return "", false
+}
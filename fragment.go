@@ -0,0 +1,67 @@
+package sqlbind
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefineFragment defines a named SQL fragment in the default binder. See (*SQLBinder).DefineFragment.
+func DefineFragment(name, sql string) {
+	defaultBinder.DefineFragment(name, sql)
+}
+
+// DefineFragment defines a named SQL fragment, included in queries using {>name}. Fragments can contain placeholders,
+// variables, ::names/::values tags, conditional sections and other fragments. Sections need to be closed within their fragment.
+//
+//  s.DefineFragment("user_cols", "u.id, u.name, u.email")
+//  s.Named("SELECT {>user_cols} FROM users u WHERE u.id=:id", arg)
+func (s *SQLBinder) DefineFragment(name, sql string) {
+	s.Lock()
+	defer s.Unlock()
+	if s.fragments == nil {
+		s.fragments = map[string]string{}
+	}
+	s.fragments[name] = sql
+	s.cache = map[string]*decoded{}
+}
+
+// includeFragments replaces fragment parts by the decoded fragments. path contains the fragments being included,
+// in order to detect cycles.
+func (s *SQLBinder) includeFragments(c *decoded, path []string) (*decoded, error) {
+	if !c.hasType(typeFragment) {
+		return c, nil
+	}
+	n := &decoded{parts: make([]part, 0, len(c.parts)), types: map[int]struct{}{}}
+	for t := range c.types {
+		if t != typeFragment {
+			n.types[t] = struct{}{}
+		}
+	}
+	for _, p := range c.parts {
+		if p.t != typeFragment {
+			n.parts = append(n.parts, p)
+			continue
+		}
+		for _, name := range path {
+			if name == p.data {
+				return nil, fmt.Errorf("Fragment cycle : %s > %s", strings.Join(path, " > "), p.data)
+			}
+		}
+		sql, found := s.fragments[p.data]
+		if !found {
+			return nil, fmt.Errorf("Unknown fragment %s", p.data)
+		}
+		f, err := decode(sql)
+		if err != nil {
+			return nil, err
+		}
+		if f, err = s.includeFragments(f, append(path[:len(path):len(path)], p.data)); err != nil {
+			return nil, err
+		}
+		n.parts = append(n.parts, f.parts...)
+		for t := range f.types {
+			n.types[t] = struct{}{}
+		}
+	}
+	return n, nil
+}
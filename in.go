@@ -0,0 +1,253 @@
+package sqlbind
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// InMode defines how slices are bound in IN clauses
+type InMode int
+
+const (
+	// InExpand expands slices to one placeholder per element, e.g. IN(?, ?, ?) (default)
+	InExpand = InMode(iota)
+	// InAny binds slices as a single array parameter, rewriting IN($1) to = ANY($1) and NOT IN($1) to <> ALL($1).
+	// It is only supported by the PostgreSQL style. Empty slices are bound as empty arrays (= ANY matches nothing,
+	// <> ALL matches everything) : EmptySliceError applies, other EmptySlice policies are ignored.
+	InAny
+)
+
+// ArrayEncoder converts a slice to a value supported by the driver as an array parameter (e.g. pq.Array)
+type ArrayEncoder func(interface{}) interface{}
+
+// EmptySlice defines how empty slices are expanded in IN clauses
+type EmptySlice int
+
+const (
+	// EmptySliceNothing writes nothing, e.g. IN(), which is invalid SQL (default)
+	EmptySliceNothing = EmptySlice(iota)
+	// EmptySliceError makes Named return ErrEmptySlice
+	EmptySliceError
+	// EmptySliceNull writes NULL, e.g. IN(NULL), which matches nothing
+	EmptySliceNull
+	// EmptySliceFalse rewrites the enclosing predicate to a constant, e.g. foo IN(:foo) to 1=0 and foo NOT IN(:foo) to 1=1
+	EmptySliceFalse
+)
+
+var (
+	ErrEmptySlice = errors.New("Empty slice")
+	ErrTupleArity = errors.New("Tuples do not have the same number of values")
+	// ErrTupleColumns is returned when struct tuples are bound to a tuple whose columns cannot be parsed, e.g. (lower(a), b) IN(:keys)
+	ErrTupleColumns = errors.New("Unable to parse the columns of the tuple")
+	// ErrInPredicate is returned when an IN predicate cannot be rewritten
+	ErrInPredicate = errors.New("Unable to find the IN predicate of the parameter")
+
+	identifier     = regexp.MustCompile("^\\w+$")
+	identifierPart = "(?:\\w+|`[^`]*`|\"[^\"]*\")"
+	inClause       = regexp.MustCompile("(?i)\\b(NOT\\s+)?IN\\s*\\(\\s*$")
+	tupleColumns   = regexp.MustCompile("(?i)(?:^|[^\\w.]|\\bROW\\s*)\\(([^()]*)\\)\\s*(?:NOT\\s+)?IN\\s*\\(\\s*$")
+	tupleEnd       = regexp.MustCompile("(?i)\\)\\s*(?:NOT\\s+)?IN\\s*\\(\\s*$")
+	// inPredicate matches the left operand of an IN predicate (a column or a tuple), starting at a token boundary,
+	// so that function calls (lower(name)) are not matched
+	inPredicate = regexp.MustCompile("(?i)(?:^|[^\\w.])((?:" + identifierPart + "\\.)*" + identifierPart + "\\s+|\\([^()]*\\)\\s*)(NOT\\s+)?IN\\s*\\(\\s*$")
+)
+
+// SetInMode sets how slices are bound by the default binder, and the encoder used by InAny (slices are bound as is if nil)
+func SetInMode(mode InMode, encoder ArrayEncoder) {
+	defaultBinder.SetInMode(mode, encoder)
+}
+
+// SetInMode sets how slices are bound by the binder, and the encoder used by InAny (slices are bound as is if nil).
+//
+// InAny keeps the same SQL query whatever the number of elements, enabling plan caching, and is not limited by the number of parameters.
+//
+//  s := sqlbind.New(sqlbind.PostgreSQL)
+//  s.SetInMode(sqlbind.InAny, func(v interface{}) interface{} { return pq.Array(v) })
+//  s.Named("SELECT * FROM example WHERE id IN(:ids)", arg) // SELECT * FROM example WHERE id = ANY($1)
+func (s *SQLBinder) SetInMode(mode InMode, encoder ArrayEncoder) {
+	s.inMode = mode
+	s.arrayEncoder = encoder
+}
+
+// writeAny rewrites the IN clause preceding a placeholder to = ANY( (or <> ALL( for NOT IN), and returns the array parameter
+func (s *SQLBinder) writeAny(buf *bytes.Buffer, val interface{}) interface{} {
+	if loc := inClause.FindSubmatchIndex(buf.Bytes()); loc != nil {
+		not := loc[2] != -1
+		buf.Truncate(loc[0])
+		if not {
+			buf.WriteString("<> ALL(")
+		} else {
+			buf.WriteString("= ANY(")
+		}
+	}
+	if s.arrayEncoder != nil {
+		return s.arrayEncoder(val)
+	}
+	return val
+}
+
+var (
+	tupleType   = reflect.TypeOf([]interface{}{})
+	timeType    = reflect.TypeOf(time.Time{})
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// isTupleSlice returns true for slices of structs and slices of []interface{}, which are expanded to (?, ?), (?, ?)
+func isTupleSlice(rval reflect.Value) bool {
+	t := rval.Type().Elem()
+	if t == tupleType {
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isColumnType(t)
+}
+
+// writeTuples writes a slice of tuples. Struct fields are either bound in the order of the columns of the tuple
+// preceding IN, e.g. (tenant_id, id) IN(:keys), or in declaration order when IN is not preceded by a tuple.
+func (s *SQLBinder) writeTuples(buf *bytes.Buffer, rval reflect.Value, i int, args []interface{}) (int, []interface{}, error) {
+	var columns []string
+	tuple := tupleEnd.Match(buf.Bytes())
+	if m := tupleColumns.FindSubmatch(buf.Bytes()); m != nil {
+		columns = parseColumns(string(m[1]))
+	}
+	arity := len(columns)
+	for si := 0; si < rval.Len(); si++ {
+		vals, err := s.tupleValues(rval.Index(si), columns, tuple)
+		if err != nil {
+			return i, args, err
+		}
+		if si == 0 && arity == 0 {
+			arity = len(vals)
+		}
+		if len(vals) != arity || arity == 0 {
+			return i, args, ErrTupleArity
+		}
+		if si != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteByte('(')
+		for vi, val := range vals {
+			if vi != 0 {
+				buf.WriteString(", ")
+			}
+			s.writePlaceholder(buf, i)
+			i++
+			args = append(args, val)
+		}
+		buf.WriteByte(')')
+	}
+	return i, args, nil
+}
+
+func (s *SQLBinder) tupleValues(v reflect.Value, columns []string, tuple bool) ([]interface{}, error) {
+	if v.Type() == tupleType {
+		return v.Interface().([]interface{}), nil
+	}
+	v = reflect.Indirect(v)
+	if !v.IsValid() {
+		return nil, ErrTupleArity
+	}
+	if columns == nil {
+		if tuple {
+			return nil, ErrTupleColumns
+		}
+		columns = s.declared(v.Type())
+	}
+	vals := make([]interface{}, len(columns))
+	arg := v.Interface()
+	for ci, column := range columns {
+		// lookup applies codecs, as for other parameters
+		val, found, err := s.lookup(column, arg)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to encode %s : %s", column, err)
+		}
+		if !found {
+			return nil, fmt.Errorf("Tuple column %s not found in %s", column, v.Type())
+		}
+		vals[ci] = val
+	}
+	return vals, nil
+}
+
+// parseColumns returns the unqualified, unquoted column names of a tuple, or nil if the tuple contains expressions
+func parseColumns(tuple string) []string {
+	parts := strings.Split(tuple, ",")
+	columns := make([]string, len(parts))
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if idx := strings.LastIndex(part, "."); idx != -1 {
+			part = part[idx+1:]
+		}
+		part = strings.Trim(part, "`\"")
+		if !identifier.MatchString(part) {
+			return nil
+		}
+		columns[i] = part
+	}
+	return columns
+}
+
+// SetEmptySlice sets how empty slices are expanded by the default binder
+func SetEmptySlice(policy EmptySlice) {
+	defaultBinder.emptySlice = policy
+}
+
+// SetEmptySlice sets how empty slices are expanded by the binder (EmptySliceNothing by default)
+func (s *SQLBinder) SetEmptySlice(policy EmptySlice) {
+	s.emptySlice = policy
+}
+
+// OnEmptySlice sets how empty slices are expanded, overriding the binder policy.
+//
+//  sqlbind.Named("SELECT * FROM example WHERE id IN(:ids)", arg, sqlbind.OnEmptySlice(sqlbind.EmptySliceFalse))
+func OnEmptySlice(policy EmptySlice) NamedOption {
+	return func(e *context) error {
+		e.emptySlice = policy
+		return nil
+	}
+}
+
+// writeEmptySlice writes an empty slice according to the policy. It returns true if the closing parenthesis
+// of the IN clause needs to be removed.
+func writeEmptySlice(buf *bytes.Buffer, policy EmptySlice) (bool, error) {
+	switch policy {
+	case EmptySliceError:
+		return false, ErrEmptySlice
+	case EmptySliceNull:
+		buf.WriteString("NULL")
+	case EmptySliceFalse:
+		loc := inPredicate.FindSubmatchIndex(buf.Bytes())
+		// NOT is matched as the operand when the actual operand cannot be isolated, e.g. lower(name) NOT IN(
+		if loc == nil || strings.EqualFold(strings.TrimSpace(string(buf.Bytes()[loc[2]:loc[3]])), "NOT") {
+			return false, ErrInPredicate
+		}
+		not := loc[4] != -1
+		buf.Truncate(loc[2])
+		if not {
+			buf.WriteString("1=1")
+		} else {
+			buf.WriteString("1=0")
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// trimClosingParenthesis removes the closing parenthesis of a rewritten IN clause
+func trimClosingParenthesis(sql string) (string, error) {
+	trimmed := strings.TrimLeft(sql, " \t\r\n")
+	if !strings.HasPrefix(trimmed, ")") {
+		return "", ErrInPredicate
+	}
+	return trimmed[1:], nil
+}
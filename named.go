@@ -2,6 +2,7 @@ package sqlbind
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"reflect"
@@ -24,10 +25,19 @@ var (
 type Style int
 
 type SQLBinder struct {
-	style Style
+	style          Style
+	mapper         *nameMapper
+	emptySlice     EmptySlice
+	inMode         InMode
+	arrayEncoder   ArrayEncoder
+	strict         bool
+	strictVars     bool
+	converters     map[reflect.Type]codec
+	noRawVariables bool
 
 	sync.Mutex
-	cache map[string]*decoded
+	cache     map[string]*decoded
+	fragments map[string]string
 }
 
 // New creates a SQLBinder object, using the specified placeholder style (MySQL or PostgreSQL)
@@ -43,11 +53,58 @@ func SetStyle(style Style) {
 	defaultBinder.style = style
 }
 
+// SetNameMapper sets the name mapper of the default binder
+func SetNameMapper(mapper NameMapper) {
+	defaultBinder.SetNameMapper(mapper)
+}
+
+// SetNameMapper sets the function used to map the names of struct fields without a db tag name to column names.
+// By default, the field name is used verbatim. Types need to be registered again after changing the name mapper.
+//
+//   s.SetNameMapper(sqlbind.SnakeCase) // CreatedAt -> created_at
+func (s *SQLBinder) SetNameMapper(mapper NameMapper) {
+	if mapper == nil {
+		s.mapper = nil
+		return
+	}
+	s.mapper = &nameMapper{fn: mapper}
+}
+
+// SetStrict sets the strict mode of the default binder
+func SetStrict(strict bool) {
+	defaultBinder.strict = strict
+}
+
+// SetStrict sets the strict mode of the binder. In strict mode, Named returns an error if a placeholder has no value,
+// neither in arg nor in Args/ArgData. Explicitly nil values are allowed.
+func (s *SQLBinder) SetStrict(strict bool) {
+	s.strict = strict
+}
+
+type statement int
+
+const (
+	anyStatement = statement(iota)
+	insertStatement
+	updateStatement
+)
+
 type context struct {
-	parts   []part
-	names   []string
-	decoded *decoded
-	args    []interface{}
+	binder     *SQLBinder
+	parts      []part
+	names      []string
+	decoded    *decoded
+	arg        interface{}
+	args       []interface{}
+	kind       statement
+	emptySlice EmptySlice
+	strict     bool
+	strictVars bool
+	// only is true if names were set using Only, missing fields are then kept
+	only bool
+	// all contains names including missing fields, used by MissingAsDefault
+	all              []string
+	missingAsDefault bool
 }
 
 type NamedOption func(*context) error
@@ -58,7 +115,8 @@ type NamedOption func(*context) error
 //   sql, args, err := sqlbind.Named("SELECT * FROM example WHERE foo=:foo", arg)
 //   rows, err := db.Query(sql, args...)
 //
-// args can either be a map[string]interface{} or a struct
+// arg can either be a map with string keys, a struct, an ArgSource, a []sql.NamedArg or url.Values.
+// Other types return ErrUnsupportedFormat.
 func Named(sql string, arg interface{}, opts ...NamedOption) (string, []interface{}, error) {
 	return defaultBinder.Named(sql, arg, opts...)
 }
@@ -69,21 +127,38 @@ func Named(sql string, arg interface{}, opts ...NamedOption) (string, []interfac
 //   sql, args, err := sqlbind.Named("SELECT * FROM example WHERE foo=:foo", arg)
 //   rows, err := db.Query(sql, args...)
 //
-// args can either be a map[string]interface{} or a struct
+// arg can either be a map with string keys, a struct, an ArgSource, a []sql.NamedArg or url.Values.
+// Other types return ErrUnsupportedFormat.
 func (s *SQLBinder) Named(sql string, arg interface{}, opts ...NamedOption) (string, []interface{}, error) {
-	var c *decoded
-	var found bool
+	c, err := s.decode(sql)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.named(c, arg, opts...)
+}
+
+// decode returns the decoded query, from the cache if available
+func (s *SQLBinder) decode(sql string) (*decoded, error) {
 	s.Lock()
-	if c, found = s.cache[sql]; !found {
-		c = decode(sql)
-		// TODO : test compilation error
+	defer s.Unlock()
+	c, found := s.cache[sql]
+	if !found {
+		d, err := decode(sql)
+		if err != nil {
+			return nil, err
+		}
+		if c, err = s.includeFragments(d, nil); err != nil {
+			return nil, err
+		}
 		s.cache[sql] = c
 	}
-	s.Unlock()
-	return s.named(c, arg, opts...)
+	return c, nil
 }
 
-// Variables sets variable values. If a variable has no value, it is replaced with an empty string.
+// Variables sets variable values. If a variable has no value, it is replaced with its default value ({name:default}),
+// or with an empty string.
+//
+// Values are inserted verbatim in the SQL query : never use Variables with user input, use Identifier instead.
 //
 //   sqlbind.Named("SELECT /* {comment} */ * FROM {table_prefix}example WHERE foo=:foo", args, sqlbind.Variables("comment", "foobar", "table_prefix", "foo_"))
 func Variables(vars ...string) NamedOption {
@@ -96,25 +171,15 @@ func Variables(vars ...string) NamedOption {
 	}
 
 	return func(e *context) error {
-		if !e.decoded.hasType(typeVariable) {
-			return nil
-		}
-		n := make([]part, 0, len(e.parts))
-		for _, p := range e.parts {
-			if p.t == typeVariable {
-				if val, ok := v[p.data]; ok {
-					n = append(n, part{t: typeSQL, data: val})
-					continue
-				}
-			}
-			n = append(n, p)
+		if e.binder.noRawVariables {
+			return ErrRawVariables
 		}
-		e.parts = n
+		setVariables(e, v)
 		return nil
 	}
 }
 
-// Only sets the list of parameters to be used in ::names, ::values and ::name=::value tags.
+// Only sets the list of parameters to be used in ::names, ::values, ::name=::value and ::match tags.
 //
 // 	var e struct {
 // 		Foo string `db:"foo"`
@@ -129,11 +194,12 @@ func Variables(vars ...string) NamedOption {
 func Only(names ...string) NamedOption {
 	return func(e *context) error {
 		e.names = names
+		e.only = true
 		return nil
 	}
 }
 
-// Exclude removes parameters from ::names, ::values and ::name=::value tags.
+// Exclude removes parameters from ::names, ::values, ::name=::value and ::match tags.
 //
 // 	var e struct {
 // 		Foo string `db:"foo"`
@@ -163,7 +229,99 @@ func Exclude(names ...string) NamedOption {
 	}
 }
 
-// Args adds additional args to be used as named parameters.
+// OnlyGroups restricts the parameters used in ::names, ::values, ::name=::value and ::match tags to fields belonging to one of the groups.
+//
+// 	var e struct {
+// 		Name   string `db:"name"`
+// 		Street string `db:"street,group=address"`
+// 		City   string `db:"city,group=address"`
+// 	}
+//  sqlbind.Named("UPDATE example SET ::name=::value", arg, sqlbind.OnlyGroups("address"))
+//
+// would be equivalent to :
+//
+//  sqlbind.Named("UPDATE example SET city=:city, street=:street", arg)
+func OnlyGroups(groups ...string) NamedOption {
+	return func(e *context) error {
+		e.names = e.filterTag(e.names, true, groupOpts(groups)...)
+		return nil
+	}
+}
+
+// ExcludeGroups removes fields belonging to one of the groups from ::names, ::values, ::name=::value and ::match tags.
+//
+// 	var e struct {
+// 		Name      string    `db:"name"`
+// 		CreatedAt time.Time `db:"created_at,group=audit"`
+// 		CreatedBy string    `db:"created_by,group=audit"`
+// 	}
+//  sqlbind.Named("UPDATE example SET ::name=::value", arg, sqlbind.ExcludeGroups("audit"))
+//
+// would be equivalent to :
+//
+//  sqlbind.Named("UPDATE example SET name=:name", arg)
+func ExcludeGroups(groups ...string) NamedOption {
+	return func(e *context) error {
+		e.names = e.filterTag(e.names, false, groupOpts(groups)...)
+		return nil
+	}
+}
+
+func groupOpts(groups []string) []string {
+	opts := make([]string, len(groups))
+	for i, group := range groups {
+		opts[i] = "group=" + group
+	}
+	return opts
+}
+
+// Strict makes Named return an error if a placeholder has no value, neither in arg nor in Args/ArgData.
+// Explicitly nil values are allowed.
+//
+//  sqlbind.Named("SELECT * FROM example WHERE name=:nmae", arg, sqlbind.Strict()) // No value for placeholders : nmae
+func Strict() NamedOption {
+	return func(e *context) error {
+		e.strict = true
+		return nil
+	}
+}
+
+// MissingAsDefault keeps missing fields (see Missinger, nil pointers, omitempty) in ::names and ::values, the DEFAULT keyword
+// being used as their value. All rows of a multi-row insert then have the same columns. ::name=::value and ::match still
+// skip missing fields.
+//
+//  sqlbind.Named("INSERT INTO example (::names) VALUES(::values)", arg, sqlbind.MissingAsDefault())
+//  // INSERT INTO example (id, name) VALUES(DEFAULT, ?)
+func MissingAsDefault() NamedOption {
+	return func(e *context) error {
+		e.missingAsDefault = true
+		return nil
+	}
+}
+
+// ForInsert tells Named that the query is an INSERT statement : fields tagged updateonly are not expanded.
+//
+// By default, ::values (and ::names when used with ::values) exclude updateonly fields, and ::name=::value excludes insertonly fields.
+//
+//  sqlbind.Named("INSERT INTO example (::names) SELECT ::names FROM other", arg, sqlbind.ForInsert())
+func ForInsert() NamedOption {
+	return func(e *context) error {
+		e.kind = insertStatement
+		return nil
+	}
+}
+
+// ForUpdate tells Named that the query is an UPDATE statement : fields tagged insertonly are not expanded.
+//
+//  sqlbind.Named("UPDATE example SET (::names) = (SELECT ::names FROM other)", arg, sqlbind.ForUpdate())
+func ForUpdate() NamedOption {
+	return func(e *context) error {
+		e.kind = updateStatement
+		return nil
+	}
+}
+
+// Args adds additional args (maps, structs or ArgSources) to be used as named parameters.
 //
 // 	var e struct {
 // 		Bar string `db:"bar"`
@@ -214,25 +372,60 @@ func errorOption(err error) NamedOption {
 	}
 }
 
-// replaceNamesValues replaces ::names, ::values and ::name=::value parts with placeholders
+// replaceNamesValues replaces ::names, ::values, ::name=::value, ::match and ::pk parts with placeholders
 func replaceNamesValues(e *context) error {
-	if !e.decoded.hasType(typeNames) && !e.decoded.hasType(typeValues) && !e.decoded.hasType(typeNameValue) {
+	if !e.decoded.hasType(typeNames) && !e.decoded.hasType(typeValues) && !e.decoded.hasType(typeNameValue) && !e.decoded.hasType(typeMatch) && !e.decoded.hasType(typePK) {
 		return nil
 	}
+	var insertNames, updateNames []string
+	var missing map[string]struct{}
+	all := e.names
+	if e.missingAsDefault && e.kind != updateStatement {
+		all = e.all
+		missing = map[string]struct{}{}
+		for _, name := range e.all {
+			missing[name] = struct{}{}
+		}
+		for _, name := range e.names {
+			delete(missing, name)
+		}
+	}
+	switch e.kind {
+	case insertStatement:
+		insertNames = e.filterTag(all, false, "updateonly")
+		updateNames = e.filterTag(e.names, false, "updateonly")
+	case updateStatement:
+		updateNames = e.filterTag(e.names, false, "insertonly")
+		insertNames = updateNames
+	default:
+		insertNames = all
+		if e.decoded.hasType(typeValues) {
+			insertNames = e.filterTag(all, false, "updateonly")
+		}
+		updateNames = e.filterTag(e.names, false, "insertonly")
+	}
+	// pk fields are kept when explicitly listed using Only
+	if e.decoded.hasType(typeNameValue) && !e.only {
+		updateNames = e.filterTag(updateNames, false, "pk")
+	}
 	n := make([]part, 0, len(e.parts)+len(e.names)*2)
 	for _, p := range e.parts {
 		switch p.t {
 		case typeNames:
-			n = append(n, part{t: typeSQL, data: strings.Join(e.names, ", ")})
+			n = append(n, part{t: typeSQL, data: strings.Join(insertNames, ", ")})
 		case typeValues:
-			for i, name := range e.names {
+			for i, name := range insertNames {
 				if i > 0 {
 					n = append(n, part{t: typeSQL, data: ", "})
 				}
+				if _, found := missing[name]; found {
+					n = append(n, part{t: typeSQL, data: string(Default)})
+					continue
+				}
 				n = append(n, part{t: typePlaceholder, data: name})
 			}
 		case typeNameValue:
-			for i, name := range e.names {
+			for i, name := range updateNames {
 				if i > 0 {
 					n = append(n, part{t: typeSQL, data: ", " + name + "="})
 				} else {
@@ -240,6 +433,18 @@ func replaceNamesValues(e *context) error {
 				}
 				n = append(n, part{t: typePlaceholder, data: name})
 			}
+		case typeMatch:
+			n = appendMatch(n, e, e.names)
+		case typePK:
+			v := reflect.Indirect(reflect.ValueOf(e.arg))
+			if !v.IsValid() || v.Kind() != reflect.Struct {
+				return ErrNoPrimaryKey
+			}
+			pks := e.binder.primaryKeys(v.Type())
+			if len(pks) == 0 {
+				return ErrNoPrimaryKey
+			}
+			n = appendMatch(n, e, pks)
 		default:
 			n = append(n, p)
 		}
@@ -248,6 +453,65 @@ func replaceNamesValues(e *context) error {
 	return nil
 }
 
+// filterTag returns the names that have (keep) or do not have (!keep) any of the opts tag options
+func (e *context) filterTag(names []string, keep bool, opts ...string) []string {
+	v := reflect.Indirect(reflect.ValueOf(e.arg))
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		if keep {
+			return []string{}
+		}
+		return names
+	}
+	is := e.binder.indexes(v.Type())
+	n := make([]string, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, opt := range opts {
+			if is[name].opts.contains(opt) {
+				found = true
+				break
+			}
+		}
+		if found == keep {
+			n = append(n, name)
+		}
+	}
+	return n
+}
+
+// appendMatch appends name=? conditions joined with AND. nil values are matched using IS NULL and slices using IN.
+// An empty list of names matches everything.
+func appendMatch(n []part, e *context, names []string) []part {
+	if len(names) == 0 {
+		return append(n, part{t: typeSQL, data: "1=1"})
+	}
+	for i, name := range names {
+		sep := ""
+		if i > 0 {
+			sep = " AND "
+		}
+		val, _, _ := e.binder.value(name, e.arg, e.args...)
+		switch {
+		case isNull(val):
+			n = append(n, part{t: typeSQL, data: sep + name + " IS NULL"})
+		case isSubQuery(val) || shouldExpandSlice(reflect.ValueOf(val)):
+			n = append(n, part{t: typeSQL, data: sep + name + " IN("}, part{t: typePlaceholder, data: name}, part{t: typeSQL, data: ")"})
+		default:
+			n = append(n, part{t: typeSQL, data: sep + name + "="}, part{t: typePlaceholder, data: name})
+		}
+	}
+	return n
+}
+
+// isNull returns true for nil values and nil pointers
+func isNull(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	rval := reflect.ValueOf(val)
+	return rval.Kind() == reflect.Ptr && rval.IsNil()
+}
+
 var bufPool sync.Pool
 
 func newBuf() *bytes.Buffer {
@@ -260,51 +524,172 @@ func newBuf() *bytes.Buffer {
 }
 
 func (s *SQLBinder) named(c *decoded, arg interface{}, opts ...NamedOption) (string, []interface{}, error) {
+	sql := newBuf()
+	defer bufPool.Put(sql)
+	_, args, err := s.write(sql, c, 1, arg, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.convertArgs(args); err != nil {
+		return "", nil, err
+	}
+	return sql.String(), args, nil
+}
+
+// write writes a query to sql, numbering placeholders from i. It returns the next placeholder index and the args.
+func (s *SQLBinder) write(sql *bytes.Buffer, c *decoded, i int, arg interface{}, opts ...NamedOption) (int, []interface{}, error) {
+	names, err := s.names(arg)
+	if err != nil {
+		return i, nil, err
+	}
 	e := &context{
-		names:   names(arg),
-		decoded: c,
-		parts:   c.parts,
+		binder:     s,
+		names:      names,
+		decoded:    c,
+		parts:      c.parts,
+		arg:        arg,
+		emptySlice: s.emptySlice,
+		strict:     s.strict,
+		strictVars: s.strictVars,
 	}
 
 	for _, opt := range opts {
 		if err := opt(e); err != nil {
-			return "", nil, err
+			return i, nil, err
 		}
 	}
-	replaceNamesValues(e)
+	e.all = e.names
+	if !e.only {
+		e.names = s.present(e.names, arg)
+	}
+	if err := replaceNamesValues(e); err != nil {
+		return i, nil, err
+	}
+	if err := s.resolveSections(e); err != nil {
+		return i, nil, err
+	}
 
 	args := make([]interface{}, 0, len(e.names))
-	sql := newBuf()
-	defer bufPool.Put(sql)
-	i := 1
+	closeIn := false
+	missing := []string{}
+	missingVars := []string{}
 	for _, p := range e.parts {
 		switch p.t {
 		case typeVariable:
+			if !p.hasDef && e.strictVars {
+				missingVars = appendMissing(missingVars, p.data)
+				continue
+			}
+			sql.WriteString(p.def)
 		case typeSQL:
-			sql.WriteString(p.data)
+			data := p.data
+			if closeIn {
+				if data, err = trimClosingParenthesis(data); err != nil {
+					return i, nil, err
+				}
+				closeIn = false
+			}
+			sql.WriteString(data)
 		case typePlaceholder:
-			val, _ := value(p.data, arg, e.args...)
-			if rval := reflect.ValueOf(val); shouldExpandSlice(rval) {
-				for si := 0; si < rval.Len(); si++ {
-					if si != 0 {
-						sql.WriteString(", ")
+			val, found, err := s.value(p.data, arg, e.args...)
+			if err != nil {
+				return i, nil, fmt.Errorf("Unable to encode %s : %s", p.data, err)
+			}
+			if !found && e.strict {
+				missing = appendMissing(missing, p.data)
+				continue
+			}
+			if raw, ok := val.(Raw); ok {
+				sql.WriteString(string(raw))
+			} else if sub, ok := val.(SubQuery); ok {
+				var subArgs []interface{}
+				if i, subArgs, err = s.writeSub(sql, sub, i); err != nil {
+					return i, nil, err
+				}
+				args = append(args, subArgs...)
+			} else if rval := reflect.ValueOf(val); shouldExpandSlice(rval) && s.inMode == InAny && s.style == PostgreSQL && !isTupleSlice(rval) {
+				// empty arrays are valid with ANY/ALL, only EmptySliceError applies
+				if rval.Len() == 0 && e.emptySlice == EmptySliceError {
+					return i, nil, ErrEmptySlice
+				}
+				val = s.writeAny(sql, val)
+				s.writePlaceholder(sql, i)
+				i++
+				args = append(args, val)
+			} else if shouldExpandSlice(rval) {
+				switch {
+				case rval.Len() == 0:
+					closeIn, err = writeEmptySlice(sql, e.emptySlice)
+				case isTupleSlice(rval):
+					i, args, err = s.writeTuples(sql, rval, i, args)
+				default:
+					for si := 0; si < rval.Len(); si++ {
+						if si != 0 {
+							sql.WriteString(", ")
+						}
+						s.writePlaceholder(sql, i)
+						i++
+						args = append(args, rval.Index(si).Interface())
 					}
-					s.writePlaceholder(sql, i)
-					i++
-					args = append(args, rval.Index(si).Interface())
+				}
+				if err != nil {
+					return i, nil, err
 				}
 			} else {
+				if ne, ok := val.(NoExpandValue); ok {
+					val = ne.Value
+				}
 				s.writePlaceholder(sql, i)
 				i++
 				args = append(args, val)
 			}
 		default:
-			return "", nil, errors.New("Unhandled part type")
+			return i, nil, errors.New("Unhandled part type")
 		}
 	}
-	return sql.String(), args, nil
+	if closeIn {
+		return i, nil, ErrInPredicate
+	}
+	if len(missingVars) > 0 {
+		return i, nil, fmt.Errorf("No value for variables : %s", strings.Join(missingVars, ", "))
+	}
+	if len(missing) > 0 {
+		return i, nil, fmt.Errorf("No value for placeholders : %s", strings.Join(missing, ", "))
+	}
+	return i, args, nil
 }
 
+func appendMissing(missing []string, name string) []string {
+	for _, m := range missing {
+		if m == name {
+			return missing
+		}
+	}
+	return append(missing, name)
+}
+
+// NoExpandValue wraps a value that is bound as a single parameter, even if it is a slice. See NoExpand.
+type NoExpandValue struct {
+	Value interface{}
+}
+
+// NoExpand prevents the automatic IN clause expansion of a slice, which is bound as a single parameter.
+//
+//  sqlbind.Named("SELECT * FROM example WHERE tags && :tags", map[string]interface{}{"tags": sqlbind.NoExpand(tags)})
+func NoExpand(v interface{}) NoExpandValue {
+	return NoExpandValue{Value: v}
+}
+
+// Raw is a SQL expression written verbatim instead of a placeholder. Like variables, it must never contain user input.
+// Empty Raw struct fields are considered missing.
+//
+//  sqlbind.Named("UPDATE example SET ::name=::value", map[string]interface{}{"name": "foo", "updated_at": sqlbind.Raw("NOW()")})
+//  // UPDATE example SET name=?, updated_at=NOW()
+type Raw string
+
+// Default is the DEFAULT keyword, e.g. to use the default value of a column in INSERT or UPDATE statements
+const Default = Raw("DEFAULT")
+
 func shouldExpandSlice(rval reflect.Value) bool {
 	if rval.Kind() != reflect.Slice {
 		return false
@@ -313,6 +698,10 @@ func shouldExpandSlice(rval reflect.Value) bool {
 	if rval.Type().Elem().Kind() == reflect.Uint8 {
 		return false
 	}
+	// Do not expand slices converting themselves to a single value (e.g. pq.StringArray).
+	if _, ok := rval.Interface().(driver.Valuer); ok {
+		return false
+	}
 	return true
 }
 
@@ -1,8 +1,15 @@
 package sqlbind
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"net"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -242,6 +249,75 @@ func TestNamed(t *testing.T) {
 	}, tc, "embed")
 }
 
+func TestStrict(t *testing.T) {
+	type testStruct struct {
+		Foo string      `db:"foo"`
+		Bar interface{} `db:"bar"`
+	}
+	tc := []testCase{
+		{
+			src:   `SELECT * FROM foo WHERE foo=:foo AND bar=:bar AND baz=:baz`,
+			opts:  []NamedOption{Strict(), ArgData("baz", "bazbar")},
+			mySQL: `SELECT * FROM foo WHERE foo=? AND bar=? AND baz=?`,
+			pgSQL: `SELECT * FROM foo WHERE foo=$1 AND bar=$2 AND baz=$3`,
+			args:  []interface{}{"foobar", nil, "bazbar"},
+		},
+	}
+	doTest(t, map[string]interface{}{"foo": "foobar", "bar": nil}, tc, "map/strict")
+	doTest(t, testStruct{Foo: "foobar"}, tc, "struct/strict")
+
+	src := `SELECT * FROM foo WHERE foo=:foo AND bar=:nmae AND baz=:baz OR bar=:nmae`
+	if _, _, err := Named(src, testStruct{}, Strict()); err == nil || err.Error() != "No value for placeholders : nmae, baz" {
+		t.Errorf("Named should return an error listing missing placeholders, but got %v", err)
+	}
+	s := New(MySQL)
+	s.SetStrict(true)
+	if _, _, err := s.Named(src, nil); err == nil {
+		t.Error("Named should return an error in strict mode, but got none")
+	}
+	if _, _, err := Named(src, nil); err != nil {
+		t.Errorf("Named should not return an error in non strict mode, but got %s", err)
+	}
+}
+
+func TestArgSources(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `SELECT * FROM foo WHERE foo=:foo AND bar IN(:bar)`,
+			mySQL: `SELECT * FROM foo WHERE foo=? AND bar IN(?, ?)`,
+			pgSQL: `SELECT * FROM foo WHERE foo=$1 AND bar IN($2, $3)`,
+			args:  []interface{}{"foobar", "barbar", "barbaz"},
+		},
+		{
+			src:   `INSERT INTO example (::names) VALUES(::values)`,
+			opts:  []NamedOption{Exclude("bar")},
+			mySQL: `INSERT INTO example (foo) VALUES(?)`,
+			pgSQL: `INSERT INTO example (foo) VALUES($1)`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE foo=:foo AND baz=:baz`,
+			opts:  []NamedOption{Strict(), Args(NamedArgs(sql.Named("baz", 42)))},
+			mySQL: `SELECT * FROM foo WHERE foo=? AND baz=?`,
+			pgSQL: `SELECT * FROM foo WHERE foo=$1 AND baz=$2`,
+			args:  []interface{}{"foobar", 42},
+		},
+	}
+	doTest(t, Values(url.Values{"foo": {"foobar"}, "bar": {"barbar", "barbaz"}}), tc, "url.Values")
+	doTest(t, NamedArgs(sql.Named("foo", "foobar"), sql.Named("bar", []string{"barbar", "barbaz"})), tc, "sql.NamedArg")
+	type stringMap map[string]interface{}
+	doTest(t, stringMap{"foo": "foobar", "bar": []string{"barbar", "barbaz"}}, tc, "stringMap")
+	doTest(t, map[string]string{"foo": "foobar"}, tc[2:], "map[string]string")
+	doTest(t, url.Values{"foo": {"foobar"}, "bar": {"barbar", "barbaz"}}, tc, "url.Values/direct")
+	doTest(t, []sql.NamedArg{sql.Named("foo", "foobar"), sql.Named("bar", []string{"barbar", "barbaz"})}, tc, "sql.NamedArg/direct")
+
+	for _, arg := range []interface{}{42, "foo", []string{"foo"}, map[int]string{}} {
+		if _, _, err := Named("SELECT * FROM foo WHERE foo=:foo", arg); err != ErrUnsupportedFormat {
+			t.Errorf("Named should return ErrUnsupportedFormat for %T, but got %v", arg, err)
+		}
+	}
+}
+
 func TestNamedDuplicateArgs(t *testing.T) {
 	type testStruct struct {
 		Foo string      `db:"foo"`
@@ -345,6 +421,266 @@ func TestNamedIn(t *testing.T) {
 	}, tc, "struct/in")
 }
 
+type valuerSlice []string
+
+func (v valuerSlice) Value() (driver.Value, error) {
+	return "{" + strings.Join(v, ",") + "}", nil
+}
+
+func TestNamedNoExpand(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `SELECT * FROM foo WHERE foo=:foo AND bar=:bar AND baz IN(:baz)`,
+			mySQL: `SELECT * FROM foo WHERE foo=? AND bar=? AND baz IN(?, ?)`,
+			pgSQL: `SELECT * FROM foo WHERE foo=$1 AND bar=$2 AND baz IN($3, $4)`,
+			args:  []interface{}{valuerSlice{"foo", "bar"}, []string{"bar", "baz"}, "bazbar", "bazbaz"},
+		},
+	}
+	doTest(t, map[string]interface{}{
+		"foo": valuerSlice{"foo", "bar"},
+		"bar": NoExpand([]string{"bar", "baz"}),
+		"baz": []string{"bazbar", "bazbaz"},
+	}, tc, "map/noexpand")
+}
+
+func TestMatch(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `SELECT * FROM example WHERE ::match`,
+			mySQL: `SELECT * FROM example WHERE bar IN(?, ?) AND foo=? AND nil IS NULL`,
+			pgSQL: `SELECT * FROM example WHERE bar IN($1, $2) AND foo=$3 AND nil IS NULL`,
+			args:  []interface{}{"barbar", "barbaz", "foobar"},
+		},
+		{
+			src:   `SELECT * FROM example WHERE ::match`,
+			opts:  []NamedOption{Only("foo")},
+			mySQL: `SELECT * FROM example WHERE foo=?`,
+			pgSQL: `SELECT * FROM example WHERE foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `UPDATE example SET ::name=::value WHERE ::match`,
+			opts:  []NamedOption{Only("foo")},
+			mySQL: `UPDATE example SET foo=? WHERE foo=?`,
+			pgSQL: `UPDATE example SET foo=$1 WHERE foo=$2`,
+			args:  []interface{}{"foobar", "foobar"},
+		},
+		{
+			src:   `SELECT * FROM example WHERE ::match`,
+			opts:  []NamedOption{Only()},
+			mySQL: `SELECT * FROM example WHERE 1=1`,
+			pgSQL: `SELECT * FROM example WHERE 1=1`,
+			args:  []interface{}{},
+		},
+	}
+	doTest(t, map[string]interface{}{
+		"foo": "foobar",
+		"bar": []string{"barbar", "barbaz"},
+		"nil": nil,
+	}, tc, "map/match")
+	type testStructMatch struct {
+		Foo string      `db:"foo"`
+		Bar []string    `db:"bar"`
+		Nil interface{} `db:"nil"`
+	}
+	doTest(t, testStructMatch{
+		Foo: "foobar",
+		Bar: []string{"barbar", "barbaz"},
+	}, tc, "struct/match")
+	doTest(t, map[string]interface{}{"foo": "foobar", "ptr": (*int)(nil)}, []testCase{
+		{
+			src:   `SELECT * FROM example WHERE ::match`,
+			mySQL: `SELECT * FROM example WHERE foo=? AND ptr IS NULL`,
+			pgSQL: `SELECT * FROM example WHERE foo=$1 AND ptr IS NULL`,
+			args:  []interface{}{"foobar"},
+		},
+	}, "map/match/nil pointer")
+}
+
+func TestNamedEmptySlice(t *testing.T) {
+	arg := map[string]interface{}{
+		"foo": "foobar",
+		"bar": []string{},
+	}
+	doTest(t, arg, []testCase{
+		{
+			src:   `SELECT * FROM foo WHERE foo=:foo AND bar IN(:bar)`,
+			mySQL: `SELECT * FROM foo WHERE foo=? AND bar IN()`,
+			pgSQL: `SELECT * FROM foo WHERE foo=$1 AND bar IN()`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE foo=:foo AND bar IN(:bar)`,
+			opts:  []NamedOption{OnEmptySlice(EmptySliceNull)},
+			mySQL: `SELECT * FROM foo WHERE foo=? AND bar IN(NULL)`,
+			pgSQL: `SELECT * FROM foo WHERE foo=$1 AND bar IN(NULL)`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE bar IN ( :bar ) AND foo=:foo`,
+			opts:  []NamedOption{OnEmptySlice(EmptySliceFalse)},
+			mySQL: `SELECT * FROM foo WHERE 1=0 AND foo=?`,
+			pgSQL: `SELECT * FROM foo WHERE 1=0 AND foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   "SELECT * FROM foo f WHERE f.`bar` NOT IN(:bar) AND foo=:foo",
+			opts:  []NamedOption{OnEmptySlice(EmptySliceFalse)},
+			mySQL: `SELECT * FROM foo f WHERE 1=1 AND foo=?`,
+			pgSQL: `SELECT * FROM foo f WHERE 1=1 AND foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE (bar)NOT IN(:bar) AND foo=:foo`,
+			opts:  []NamedOption{OnEmptySlice(EmptySliceFalse)},
+			mySQL: `SELECT * FROM foo WHERE 1=1 AND foo=?`,
+			pgSQL: `SELECT * FROM foo WHERE 1=1 AND foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE ::match`,
+			opts:  []NamedOption{OnEmptySlice(EmptySliceFalse)},
+			mySQL: `SELECT * FROM foo WHERE 1=0 AND foo=?`,
+			pgSQL: `SELECT * FROM foo WHERE 1=0 AND foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+	}, "map/empty")
+
+	s := New(MySQL)
+	s.SetEmptySlice(EmptySliceError)
+	if _, _, err := s.Named(`SELECT * FROM foo WHERE bar IN(:bar)`, arg); err != ErrEmptySlice {
+		t.Errorf("Named should return ErrEmptySlice, but got %v", err)
+	}
+	if _, _, err := s.Named(`SELECT * FROM foo WHERE bar IN(:bar)`, arg, OnEmptySlice(EmptySliceNull)); err != nil {
+		t.Errorf("OnEmptySlice should override the binder policy, but got %v", err)
+	}
+	for _, src := range []string{
+		`SELECT * FROM foo WHERE bar=ANY(:bar)`,
+		`SELECT * FROM foo WHERE lower(bar) IN(:bar)`,
+		`SELECT * FROM foo WHERE lower(bar) NOT IN(:bar)`,
+		`SELECT * FROM foo WHERE coalesce(bar, (baz)) IN(:bar)`,
+	} {
+		if _, _, err := s.Named(src, arg, OnEmptySlice(EmptySliceFalse)); err != ErrInPredicate {
+			t.Errorf("Named should return ErrInPredicate for %s, but got %v", src, err)
+		}
+	}
+}
+
+func TestNamedInTuples(t *testing.T) {
+	type key struct {
+		ID       int    `db:"id"`
+		TenantID string `db:"tenant_id"`
+	}
+	tc := []testCase{
+		{
+			src:   `SELECT * FROM foo WHERE (tenant_id, f.id) IN (:keys)`,
+			mySQL: `SELECT * FROM foo WHERE (tenant_id, f.id) IN ((?, ?), (?, ?))`,
+			pgSQL: `SELECT * FROM foo WHERE (tenant_id, f.id) IN (($1, $2), ($3, $4))`,
+			args:  []interface{}{"a", 1, "b", 2},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE ROW(id, tenant_id) IN (:keys)`,
+			mySQL: `SELECT * FROM foo WHERE ROW(id, tenant_id) IN ((?, ?), (?, ?))`,
+			pgSQL: `SELECT * FROM foo WHERE ROW(id, tenant_id) IN (($1, $2), ($3, $4))`,
+			args:  []interface{}{1, "a", 2, "b"},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE (tenant_id,id)NOT IN(:keys)`,
+			mySQL: `SELECT * FROM foo WHERE (tenant_id,id)NOT IN((?, ?), (?, ?))`,
+			pgSQL: `SELECT * FROM foo WHERE (tenant_id,id)NOT IN(($1, $2), ($3, $4))`,
+			args:  []interface{}{"a", 1, "b", 2},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE (a, b) IN (:rows)`,
+			mySQL: `SELECT * FROM foo WHERE (a, b) IN ((?, ?), (?, ?))`,
+			pgSQL: `SELECT * FROM foo WHERE (a, b) IN (($1, $2), ($3, $4))`,
+			args:  []interface{}{"a", 1, "b", 2},
+		},
+	}
+	doTest(t, map[string]interface{}{
+		"keys": []*key{{ID: 1, TenantID: "a"}, {ID: 2, TenantID: "b"}},
+		"rows": [][]interface{}{{"a", 1}, {"b", 2}},
+	}, tc, "map/tuples")
+
+	for _, rows := range [][][]interface{}{
+		{{"a", 1}, {"b"}},
+		{{"a", 1, 2}},
+	} {
+		if _, _, err := Named(`SELECT * FROM foo WHERE (a, b) IN (:rows)`, map[string]interface{}{"rows": rows}); err != ErrTupleArity {
+			t.Errorf("Named should return ErrTupleArity for %v, but got %v", rows, err)
+		}
+	}
+	if _, _, err := Named(`SELECT * FROM foo WHERE (id, foo) IN (:keys)`, map[string]interface{}{"keys": []key{{}}}); err == nil {
+		t.Error("Named should return an error for unknown tuple columns, but got none")
+	}
+	for _, src := range []string{
+		`SELECT * FROM foo WHERE (lower(tenant_id), id) IN (:keys)`,
+		`SELECT * FROM foo WHERE coalesce(tenant_id, id) IN (:keys)`,
+		`SELECT * FROM foo WHERE (tenant_id, id + 1) IN (:keys)`,
+	} {
+		if _, _, err := Named(src, map[string]interface{}{"keys": []key{{}}}); err != ErrTupleColumns {
+			t.Errorf("Named should return ErrTupleColumns for %s, but got %v", src, err)
+		}
+	}
+}
+
+func TestNamedInAny(t *testing.T) {
+	type array struct {
+		v interface{}
+	}
+	arg := map[string]interface{}{
+		"foo": []string{"foobar", "foobaz"},
+		"bar": []int{},
+	}
+	s := New(PostgreSQL)
+	s.SetInMode(InAny, func(v interface{}) interface{} { return array{v} })
+	for _, it := range []struct {
+		src  string
+		sql  string
+		args []interface{}
+	}{
+		{
+			src:  `SELECT * FROM foo WHERE foo IN(:foo) AND bar NOT IN (:bar)`,
+			sql:  `SELECT * FROM foo WHERE foo = ANY($1) AND bar <> ALL($2)`,
+			args: []interface{}{array{[]string{"foobar", "foobaz"}}, array{[]int{}}},
+		},
+		{
+			src:  `SELECT * FROM foo WHERE foo=ANY(:foo)`,
+			sql:  `SELECT * FROM foo WHERE foo=ANY($1)`,
+			args: []interface{}{array{[]string{"foobar", "foobaz"}}},
+		},
+		{
+			src:  `SELECT * FROM foo WHERE ::match`,
+			sql:  `SELECT * FROM foo WHERE bar = ANY($1) AND foo = ANY($2)`,
+			args: []interface{}{array{[]int{}}, array{[]string{"foobar", "foobaz"}}},
+		},
+	} {
+		sql, args, err := s.Named(it.src, arg)
+		if err != nil {
+			t.Errorf("Unable to generate sql for '%s' : %s", it.src, err)
+		}
+		if sql != it.sql {
+			t.Errorf("Expected sql for '%s' was '%s' but got '%s'", it.src, it.sql, sql)
+		}
+		if !reflect.DeepEqual(args, it.args) {
+			t.Errorf("Expected args for '%s' were '%v' but got '%v'", it.src, it.args, args)
+		}
+	}
+
+	if _, _, err := s.Named(`SELECT * FROM foo WHERE bar IN(:bar)`, arg, OnEmptySlice(EmptySliceError)); err != ErrEmptySlice {
+		t.Errorf("Named should return ErrEmptySlice in InAny mode, but got %v", err)
+	}
+	if sql, _, err := s.Named(`SELECT * FROM foo WHERE bar IN(:bar)`, arg, OnEmptySlice(EmptySliceFalse)); err != nil || sql != `SELECT * FROM foo WHERE bar = ANY($1)` {
+		t.Errorf("Other empty slice policies should be ignored in InAny mode, but got '%s', %v", sql, err)
+	}
+
+	my := New(MySQL)
+	my.SetInMode(InAny, nil)
+	if sql, _, _ := my.Named(`SELECT * FROM foo WHERE foo IN(:foo)`, arg); sql != `SELECT * FROM foo WHERE foo IN(?, ?)` {
+		t.Errorf("InAny should be ignored by the MySQL style, but got '%s'", sql)
+	}
+}
+
 func TestRO(t *testing.T) {
 	tc := []testCase{
 		{
@@ -370,6 +706,306 @@ func TestRO(t *testing.T) {
 	}, tc, "struct/ro")
 }
 
+type zeroer string
+
+func (z zeroer) IsZero() bool {
+	return z == "zero"
+}
+
+func TestOmitEmpty(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `INSERT INTO example (::names) VALUES(::values)`,
+			mySQL: `INSERT INTO example (bar, foo) VALUES(?, ?)`,
+			pgSQL: `INSERT INTO example (bar, foo) VALUES($1, $2)`,
+			args:  []interface{}{0, "foobar"},
+		},
+		{
+			src:   `UPDATE example SET ::name=::value WHERE int=:int`,
+			mySQL: `UPDATE example SET bar=?, foo=? WHERE int=?`,
+			pgSQL: `UPDATE example SET bar=$1, foo=$2 WHERE int=$3`,
+			args:  []interface{}{0, "foobar", 0},
+		},
+	}
+	type testStructOmitEmpty struct {
+		Foo string `db:"foo,omitempty"`
+		Bar int    `db:"bar"`
+		Int int    `db:"int,omitzero"`
+		Baz zeroer `db:"baz,omitempty"`
+	}
+	doTest(t, testStructOmitEmpty{
+		Foo: "foobar",
+		Baz: "zero",
+	}, tc, "struct/omitempty")
+}
+
+func TestInsertUpdateOnly(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `INSERT INTO example (::names) VALUES(::values)`,
+			mySQL: `INSERT INTO example (bar, foo) VALUES(?, ?)`,
+			pgSQL: `INSERT INTO example (bar, foo) VALUES($1, $2)`,
+			args:  []interface{}{"barbar", "foobar"},
+		},
+		{
+			src:   `UPDATE example SET ::name=::value`,
+			mySQL: `UPDATE example SET baz=?, foo=?`,
+			pgSQL: `UPDATE example SET baz=$1, foo=$2`,
+			args:  []interface{}{"bazbar", "foobar"},
+		},
+		{
+			src:   `INSERT INTO example (::names) VALUES(::values) ON DUPLICATE KEY UPDATE ::name=::value`,
+			mySQL: `INSERT INTO example (bar, foo) VALUES(?, ?) ON DUPLICATE KEY UPDATE baz=?, foo=?`,
+			pgSQL: `INSERT INTO example (bar, foo) VALUES($1, $2) ON DUPLICATE KEY UPDATE baz=$3, foo=$4`,
+			args:  []interface{}{"barbar", "foobar", "bazbar", "foobar"},
+		},
+		{
+			src:   `SELECT ::names FROM example`,
+			mySQL: `SELECT bar, baz, foo FROM example`,
+			pgSQL: `SELECT bar, baz, foo FROM example`,
+			args:  []interface{}{},
+		},
+		{
+			src:   `SELECT ::names FROM example`,
+			opts:  []NamedOption{ForInsert()},
+			mySQL: `SELECT bar, foo FROM example`,
+			pgSQL: `SELECT bar, foo FROM example`,
+			args:  []interface{}{},
+		},
+		{
+			src:   `SELECT ::names FROM example`,
+			opts:  []NamedOption{ForUpdate()},
+			mySQL: `SELECT baz, foo FROM example`,
+			pgSQL: `SELECT baz, foo FROM example`,
+			args:  []interface{}{},
+		},
+	}
+	type testStructInsertUpdate struct {
+		Foo string `db:"foo"`
+		Bar string `db:"bar,insertonly"`
+		Baz string `db:"baz,updateonly,omitempty"`
+		Int int    `db:"int,ro,omitempty"`
+	}
+	doTest(t, testStructInsertUpdate{
+		Foo: "foobar",
+		Bar: "barbar",
+		Baz: "bazbar",
+		Int: 42,
+	}, tc, "struct/insertupdate")
+}
+
+func TestPK(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `UPDATE example SET ::name=::value WHERE ::pk`,
+			mySQL: `UPDATE example SET foo=? WHERE tenant_id=? AND id=?`,
+			pgSQL: `UPDATE example SET foo=$1 WHERE tenant_id=$2 AND id=$3`,
+			args:  []interface{}{"foobar", "tenant", 42},
+		},
+		{
+			src:   `INSERT INTO example (::names) VALUES(::values)`,
+			mySQL: `INSERT INTO example (foo, id, tenant_id) VALUES(?, ?, ?)`,
+			pgSQL: `INSERT INTO example (foo, id, tenant_id) VALUES($1, $2, $3)`,
+			args:  []interface{}{"foobar", 42, "tenant"},
+		},
+		{
+			src:   `UPDATE example SET ::name=::value WHERE ::pk`,
+			opts:  []NamedOption{Only("id", "foo")},
+			mySQL: `UPDATE example SET id=?, foo=? WHERE tenant_id=? AND id=?`,
+			pgSQL: `UPDATE example SET id=$1, foo=$2 WHERE tenant_id=$3 AND id=$4`,
+			args:  []interface{}{42, "foobar", "tenant", 42},
+		},
+		{
+			src:   `DELETE FROM example WHERE ::pk`,
+			mySQL: `DELETE FROM example WHERE tenant_id=? AND id=?`,
+			pgSQL: `DELETE FROM example WHERE tenant_id=$1 AND id=$2`,
+			args:  []interface{}{"tenant", 42},
+		},
+	}
+	type testStructPK struct {
+		TenantID string `db:"tenant_id,pk"`
+		ID       int    `db:"id,pk"`
+		Foo      string `db:"foo"`
+	}
+	doTest(t, testStructPK{
+		TenantID: "tenant",
+		ID:       42,
+		Foo:      "foobar",
+	}, tc, "struct/pk")
+
+	_, _, err := Named("DELETE FROM example WHERE ::pk", map[string]interface{}{"id": 42})
+	if err != ErrNoPrimaryKey {
+		t.Errorf("::pk with a map should return ErrNoPrimaryKey, but got %v", err)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `UPDATE example SET ::name=::value`,
+			opts:  []NamedOption{OnlyGroups("address")},
+			mySQL: `UPDATE example SET city=?, street=?`,
+			pgSQL: `UPDATE example SET city=$1, street=$2`,
+			args:  []interface{}{"paris", "rue"},
+		},
+		{
+			src:   `UPDATE example SET ::name=::value`,
+			opts:  []NamedOption{ExcludeGroups("audit")},
+			mySQL: `UPDATE example SET name=?, street=?`,
+			pgSQL: `UPDATE example SET name=$1, street=$2`,
+			args:  []interface{}{"foo", "rue"},
+		},
+		{
+			src:   `UPDATE example SET ::name=::value`,
+			opts:  []NamedOption{OnlyGroups("audit", "address"), Exclude("street")},
+			mySQL: `UPDATE example SET city=?, created_by=?`,
+			pgSQL: `UPDATE example SET city=$1, created_by=$2`,
+			args:  []interface{}{"paris", "bar"},
+		},
+	}
+	type testStructGroups struct {
+		Name      string `db:"name"`
+		Street    string `db:"street,group=address"`
+		City      string `db:"city,group=address,group=audit"`
+		CreatedBy string `db:"created_by,group=audit"`
+	}
+	doTest(t, testStructGroups{
+		Name:      "foo",
+		Street:    "rue",
+		City:      "paris",
+		CreatedBy: "bar",
+	}, tc, "struct/groups")
+}
+
+func TestPrefix(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `INSERT INTO example (::names) VALUES(::values)`,
+			mySQL: `INSERT INTO example (billing_city, billing_street, name, shipping_city, shipping_street) VALUES(?, ?, ?, ?, ?)`,
+			pgSQL: `INSERT INTO example (billing_city, billing_street, name, shipping_city, shipping_street) VALUES($1, $2, $3, $4, $5)`,
+			args:  []interface{}{"paris", "rue", "foo", "lyon", "avenue"},
+		},
+		{
+			src:   `SELECT * FROM example WHERE shipping_city=:shipping_city`,
+			mySQL: `SELECT * FROM example WHERE shipping_city=?`,
+			pgSQL: `SELECT * FROM example WHERE shipping_city=$1`,
+			args:  []interface{}{"lyon"},
+		},
+	}
+	type address struct {
+		Street string `db:"street"`
+		City   string `db:"city"`
+	}
+	type testStructPrefix struct {
+		Name     string   `db:"name"`
+		Billing  address  `db:",prefix=billing_"`
+		Shipping *address `db:",prefix=shipping_"`
+	}
+	doTest(t, testStructPrefix{
+		Name:     "foo",
+		Billing:  address{Street: "rue", City: "paris"},
+		Shipping: &address{Street: "avenue", City: "lyon"},
+	}, tc, "struct/prefix")
+}
+
+func TestCodecs(t *testing.T) {
+	RegisterCodec("upper", func(v interface{}) (interface{}, error) {
+		return strings.ToUpper(v.(string)), nil
+	}, nil)
+	tc := []testCase{
+		{
+			src:   `INSERT INTO example (::names) VALUES(::values)`,
+			mySQL: `INSERT INTO example (age, ip, meta, name, nil) VALUES(?, ?, ?, ?, ?)`,
+			pgSQL: `INSERT INTO example (age, ip, meta, name, nil) VALUES($1, $2, $3, $4, $5)`,
+			args:  []interface{}{"42", "127.0.0.1", `{"foo":"bar"}`, "FOO", nil},
+		},
+	}
+	type testStructCodecs struct {
+		Meta map[string]string `db:"meta,json"`
+		Nil  map[string]string `db:"nil,json"`
+		IP   net.IP            `db:"ip,text"`
+		Age  int               `db:"age,string"`
+		Name string            `db:"name,upper"`
+	}
+	doTest(t, testStructCodecs{
+		Meta: map[string]string{"foo": "bar"},
+		IP:   net.IPv4(127, 0, 0, 1),
+		Age:  42,
+		Name: "foo",
+	}, tc, "struct/codecs")
+
+	type tupleCodecs struct {
+		Meta map[string]int `db:"meta,json"`
+		Age  int            `db:"age,string"`
+	}
+	doTest(t, map[string]interface{}{"keys": []tupleCodecs{{Meta: map[string]int{"x": 1}, Age: 42}}}, []testCase{
+		{
+			src:   `SELECT * FROM example WHERE (age, meta) IN(:keys)`,
+			mySQL: `SELECT * FROM example WHERE (age, meta) IN((?, ?))`,
+			pgSQL: `SELECT * FROM example WHERE (age, meta) IN(($1, $2))`,
+			args:  []interface{}{"42", `{"x":1}`},
+		},
+	}, "map/tuple codecs")
+
+	// decode-only codecs are ignored by Named
+	RegisterCodec("decodeonly", nil, func(src interface{}, dst interface{}) error {
+		return nil
+	})
+	if _, args, err := Named("SELECT * FROM example WHERE name=:name", struct {
+		Name string `db:"name,decodeonly"`
+	}{Name: "foo"}); err != nil || !reflect.DeepEqual(args, []interface{}{"foo"}) {
+		t.Errorf("Decode-only codecs should be ignored by Named, but got %v, %v", args, err)
+	}
+
+	type testStructBadCodec struct {
+		Name string `db:"name,text"`
+	}
+	if _, _, err := Named("SELECT * FROM example WHERE name=:name", testStructBadCodec{}); err == nil {
+		t.Error("Named should return an error when a value cannot be encoded, but got none")
+	}
+}
+
+type testUUID [2]byte
+
+func TestConverters(t *testing.T) {
+	s := New(PostgreSQL)
+	s.RegisterConverter(reflect.TypeOf(testUUID{}), func(v interface{}) (interface{}, error) {
+		u := v.(testUUID)
+		return hex.EncodeToString(u[:]), nil
+	}, nil)
+	s.RegisterConverter(reflect.TypeOf(time.Duration(0)), func(v interface{}) (interface{}, error) {
+		return v.(time.Duration).String(), nil
+	}, nil)
+	type testStructConverter struct {
+		ID      testUUID      `db:"id"`
+		Timeout time.Duration `db:"timeout"`
+	}
+	for _, it := range []struct {
+		src  string
+		arg  interface{}
+		args []interface{}
+	}{
+		{
+			src:  `INSERT INTO example (::names) VALUES(::values)`,
+			arg:  testStructConverter{ID: testUUID{1, 2}, Timeout: time.Second},
+			args: []interface{}{"0102", "1s"},
+		},
+		{
+			src:  `SELECT * FROM example WHERE id IN(:id)`,
+			arg:  map[string]interface{}{"id": []testUUID{{1, 2}, {3, 4}}},
+			args: []interface{}{"0102", "0304"},
+		},
+	} {
+		_, args, err := s.Named(it.src, it.arg)
+		if err != nil {
+			t.Errorf("Unable to generate sql for '%s' : %s", it.src, err)
+		}
+		if !reflect.DeepEqual(args, it.args) {
+			t.Errorf("Expected args for '%s' were '%v' but got '%v'", it.src, it.args, args)
+		}
+	}
+}
+
 func TestNoTag(t *testing.T) {
 	tc := []testCase{
 		{
@@ -401,6 +1037,127 @@ func TestNoTag(t *testing.T) {
 	}, tc, "struct/notag")
 }
 
+type scannerBase struct {
+	ID int
+}
+
+func (b *scannerBase) Scan(src interface{}) error {
+	return nil
+}
+
+func TestNameMapper(t *testing.T) {
+	for name, ref := range map[string]string{
+		"Foo":        "foo",
+		"CreatedAt":  "created_at",
+		"UserID":     "user_id",
+		"HTTPServer": "http_server",
+		"Address2":   "address2",
+	} {
+		if got := SnakeCase(name); got != ref {
+			t.Errorf("SnakeCase(%s) returned %s, %s expected", name, got, ref)
+		}
+	}
+
+	type testStructMapper struct {
+		CreatedAt string
+		UserID    int
+		Foo       string `db:"FOO"`
+	}
+	arg := testStructMapper{CreatedAt: "now", UserID: 42, Foo: "foobar"}
+	snake := New(MySQL)
+	snake.SetNameMapper(SnakeCase)
+	lower := New(MySQL)
+	lower.SetNameMapper(LowerCase)
+	snake.Register(arg)
+	lower.Register(arg)
+	for _, it := range []struct {
+		s   *SQLBinder
+		sql string
+	}{
+		{s: snake, sql: `INSERT INTO example (FOO, created_at, user_id) VALUES(?, ?, ?)`},
+		{s: lower, sql: `INSERT INTO example (FOO, createdat, userid) VALUES(?, ?, ?)`},
+		{s: New(MySQL), sql: `INSERT INTO example (CreatedAt, FOO, UserID) VALUES(?, ?, ?)`},
+	} {
+		sql, _, err := it.s.Named(`INSERT INTO example (::names) VALUES(::values)`, arg)
+		if err != nil {
+			t.Errorf("Unable to generate sql : %s", err)
+		}
+		if sql != it.sql {
+			t.Errorf("Expected sql was '%s' but got '%s'", it.sql, sql)
+		}
+	}
+
+	type testStructEmbedded struct {
+		scannerBase
+		Name string
+	}
+	query, args, err := snake.Named(`INSERT INTO example (::names) VALUES(::values) WHERE id=:id`, testStructEmbedded{scannerBase: scannerBase{ID: 7}, Name: "x"})
+	if err != nil {
+		t.Errorf("Unable to generate sql : %s", err)
+	}
+	if expected := `INSERT INTO example (id, name) VALUES(?, ?) WHERE id=?`; query != expected {
+		t.Errorf("Expected sql was '%s' but got '%s'", expected, query)
+	}
+	if expected := []interface{}{7, "x", 7}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected args were '%v' but got '%v'", expected, args)
+	}
+
+	type testStructColumns struct {
+		CreatedAt time.Time
+		Name      sql.NullString
+	}
+	query, _, err = snake.Named(`INSERT INTO example (::names) VALUES(::values)`, testStructColumns{})
+	if err != nil {
+		t.Errorf("Unable to generate sql : %s", err)
+	}
+	if expected := `INSERT INTO example (created_at, name) VALUES(?, ?)`; query != expected {
+		t.Errorf("Expected sql was '%s' but got '%s'", expected, query)
+	}
+}
+
+func TestAmbiguous(t *testing.T) {
+	type a struct {
+		ID  int    `db:"id"`
+		Foo string `db:"foo"`
+	}
+	type b struct {
+		ID  int    `db:"id"`
+		Foo string `db:"foo"`
+	}
+	type testStructShallowest struct {
+		a
+		B  b   `db:",prefix=b_"`
+		ID int `db:"id"`
+	}
+	doTest(t, testStructShallowest{a: a{ID: 1}, B: b{ID: 2}, ID: 3}, []testCase{
+		{
+			src:   `SELECT * FROM example WHERE id=:id`,
+			mySQL: `SELECT * FROM example WHERE id=?`,
+			pgSQL: `SELECT * FROM example WHERE id=$1`,
+			args:  []interface{}{3},
+		},
+		{
+			src:   `INSERT INTO example (::names) VALUES(::values)`,
+			mySQL: `INSERT INTO example (b_foo, b_id, foo, id) VALUES(?, ?, ?, ?)`,
+			pgSQL: `INSERT INTO example (b_foo, b_id, foo, id) VALUES($1, $2, $3, $4)`,
+			args:  []interface{}{"", 2, "", 3},
+		},
+	}, "struct/shallowest")
+
+	type testStructAmbiguous struct {
+		a
+		b
+	}
+	if err := New(MySQL).Register(testStructAmbiguous{}); err == nil {
+		t.Error("Register should return an error for ambiguous names, but got none")
+	} else if ref := "Ambiguous column names in sqlbind.testStructAmbiguous : foo (a.Foo, b.Foo), id (a.ID, b.ID)"; err.Error() != ref {
+		t.Errorf("Register returned '%s', '%s' expected", err, ref)
+	}
+	if _, _, err := Named("SELECT * FROM example WHERE id=:id", testStructAmbiguous{}); err == nil {
+		t.Error("Named should return an error for ambiguous names, but got none")
+	}
+}
+
 type MissingField bool
 
 func (m MissingField) Missing() bool {
@@ -540,6 +1297,409 @@ func TestNullField(t *testing.T) {
 	}, "struct/null/notnull")
 }
 
+func TestIdentifier(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `SELECT * FROM {schema}.{table} WHERE foo=:foo`,
+			opts:  []NamedOption{IdentifierVariables("schema", "app", "table", "users")},
+			mySQL: "SELECT * FROM `app`.`users` WHERE foo=?",
+			pgSQL: `SELECT * FROM "app"."users" WHERE foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM {table}`,
+			opts:  []NamedOption{Identifier("table", "app.users", "app.users", "app.groups")},
+			mySQL: "SELECT * FROM `app`.`users`",
+			pgSQL: `SELECT * FROM "app"."users"`,
+			args:  []interface{}{},
+		},
+	}
+	doTest(t, map[string]interface{}{"foo": "foobar"}, tc, "identifier")
+
+	for _, opt := range []NamedOption{
+		Identifier("table", "users; DROP TABLE users"),
+		Identifier("table", "1users"),
+		Identifier("table", "roles", "users", "groups"),
+		IdentifierVariables("table", "users", "schema"),
+	} {
+		if _, _, err := Named("SELECT * FROM {table}", nil, opt); err == nil {
+			t.Error("Invalid identifiers should return an error, but got none")
+		}
+	}
+
+	s := New(MySQL)
+	s.SetRawVariables(false)
+	if _, _, err := s.Named("SELECT * FROM {table}", nil, Variables("table", "users")); err != ErrRawVariables {
+		t.Errorf("Variables should return ErrRawVariables when raw variables are disabled, but got %v", err)
+	}
+	if _, _, err := s.Named("SELECT * FROM {table}", nil, Identifier("table", "users")); err != nil {
+		t.Errorf("Identifier should not return an error when raw variables are disabled, but got %s", err)
+	}
+}
+
+func TestVariableSources(t *testing.T) {
+	type vars struct {
+		TablePrefix string `db:"table_prefix"`
+		Comment     string `db:"comment"`
+	}
+	tc := []testCase{
+		{
+			src:   `SELECT /* {comment:none} */ * FROM {table_prefix:app_}foo WHERE foo=:foo`,
+			mySQL: `SELECT /* none */ * FROM app_foo WHERE foo=?`,
+			pgSQL: `SELECT /* none */ * FROM app_foo WHERE foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT /* {comment:} */ * FROM {table_prefix:app_}foo WHERE foo=:foo`,
+			opts:  []NamedOption{VariablesMap(map[string]string{"table_prefix": "bar_"})},
+			mySQL: `SELECT /*  */ * FROM bar_foo WHERE foo=?`,
+			pgSQL: `SELECT /*  */ * FROM bar_foo WHERE foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT /* {comment:none} */ * FROM {table_prefix:app_}foo WHERE foo=:foo`,
+			opts:  []NamedOption{VariablesStruct(&vars{TablePrefix: "baz_", Comment: "bazbar"})},
+			mySQL: `SELECT /* bazbar */ * FROM baz_foo WHERE foo=?`,
+			pgSQL: `SELECT /* bazbar */ * FROM baz_foo WHERE foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM {table_prefix:app_}foo WHERE foo=:foo`,
+			opts:  []NamedOption{Variables("table_prefix", "")},
+			mySQL: `SELECT * FROM foo WHERE foo=?`,
+			pgSQL: `SELECT * FROM foo WHERE foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+	}
+	doTest(t, map[string]interface{}{"foo": "foobar"}, tc, "variables")
+
+	if _, _, err := Named("{var}", nil, VariablesStruct(struct {
+		Var int `db:"var"`
+	}{})); err == nil {
+		t.Error("VariablesStruct should return an error for non string fields, but got none")
+	}
+	if _, _, err := Named("{var}", nil, VariablesStruct("var")); err == nil {
+		t.Error("VariablesStruct should return an error for non struct args, but got none")
+	}
+	s := New(MySQL)
+	s.SetRawVariables(false)
+	if _, _, err := s.Named("{var}", nil, VariablesMap(map[string]string{"var": "foo"})); err != ErrRawVariables {
+		t.Errorf("VariablesMap should return ErrRawVariables when raw variables are disabled, but got %v", err)
+	}
+}
+
+func TestStrictVariables(t *testing.T) {
+	tc := []testCase{
+		{
+			src:   `SELECT /* {comment?} */ * FROM {table_prefix:app_}foo WHERE foo=:foo`,
+			opts:  []NamedOption{StrictVariables()},
+			mySQL: `SELECT /*  */ * FROM app_foo WHERE foo=?`,
+			pgSQL: `SELECT /*  */ * FROM app_foo WHERE foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT /* {comment?:none} */ * FROM {schema}.foo WHERE foo=:foo`,
+			opts:  []NamedOption{StrictVariables(), Variables("schema", "app")},
+			mySQL: `SELECT /* none */ * FROM app.foo WHERE foo=?`,
+			pgSQL: `SELECT /* none */ * FROM app.foo WHERE foo=$1`,
+			args:  []interface{}{"foobar"},
+		},
+	}
+	doTest(t, map[string]interface{}{"foo": "foobar"}, tc, "strict variables")
+
+	src := `SELECT * FROM {schema}.{table} JOIN {schema}.bar WHERE foo=:foo`
+	if _, _, err := Named(src, nil, StrictVariables()); err == nil || err.Error() != "No value for variables : schema, table" {
+		t.Errorf("Named should return an error listing missing variables, but got %v", err)
+	}
+	s := New(MySQL)
+	s.SetStrictVariables(true)
+	if _, _, err := s.Named(src, nil, Variables("schema", "app")); err == nil || err.Error() != "No value for variables : table" {
+		t.Errorf("Named should return an error in strict variables mode, but got %v", err)
+	}
+	if sql, _, err := Named(src, nil); err != nil || sql != "SELECT * FROM . JOIN .bar WHERE foo=?" {
+		t.Errorf("Named should not return an error in non strict variables mode, but got %q, %v", sql, err)
+	}
+}
+
+type missingString struct {
+	val     string
+	missing bool
+}
+
+func (m missingString) Missing() bool {
+	return m.missing
+}
+
+func TestSections(t *testing.T) {
+	type testStruct struct {
+		Name   string        `db:"name"`
+		Status *string       `db:"status"`
+		IDs    []int         `db:"ids"`
+		Kind   missingString `db:"kind"`
+	}
+	tc := []testCase{
+		{
+			src:   `SELECT * FROM foo WHERE 1=1 [[ AND name=:name ]][[ AND status=:status]][[ AND id IN(:ids) ]][[ AND kind=:kind ]]`,
+			mySQL: `SELECT * FROM foo WHERE 1=1  AND name=? `,
+			pgSQL: `SELECT * FROM foo WHERE 1=1  AND name=$1 `,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE 1=1 [[ AND status=:status [[ AND name=:name ]]]][[ AND name=:name [[ AND kind=:kind]] ]]`,
+			mySQL: `SELECT * FROM foo WHERE 1=1  AND name=?  `,
+			pgSQL: `SELECT * FROM foo WHERE 1=1  AND name=$1  `,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE a[b[1]]=:name [[ AND bar=:bar]]`,
+			opts:  []NamedOption{ArgData("bar", "barbar")},
+			mySQL: `SELECT * FROM foo WHERE a[b[1]]=?  AND bar=?`,
+			pgSQL: `SELECT * FROM foo WHERE a[b[1]]=$1  AND bar=$2`,
+			args:  []interface{}{"foobar", "barbar"},
+		},
+	}
+	doTest(t, testStruct{Name: "foobar", IDs: []int{}, Kind: missingString{missing: true}}, tc, "struct/sections")
+	doTest(t, map[string]interface{}{"name": "foobar", "status": nil, "ids": []int{}, "kind": missingString{missing: true}}, tc, "map/sections")
+
+	status := "active"
+	tc = []testCase{
+		{
+			src:   `SELECT * FROM foo WHERE 1=1 [[ AND status=:status]][[ AND id IN(:ids) ]]`,
+			mySQL: `SELECT * FROM foo WHERE 1=1  AND status=? AND id IN(?, ?) `,
+			pgSQL: `SELECT * FROM foo WHERE 1=1  AND status=$1 AND id IN($2, $3) `,
+			args:  []interface{}{&status, 1, 2},
+		},
+	}
+	doTest(t, testStruct{Status: &status, IDs: []int{1, 2}}, tc, "struct/sections/present")
+
+	for src, expected := range map[string]string{
+		`SELECT ARRAY[[1,2],[3,4]] FROM foo WHERE name=:name`:                        `SELECT ARRAY[[1,2],[3,4]] FROM foo WHERE name=$1`,
+		`SELECT ARRAY [[1,2],[3,4]] FROM foo [[ AND a = ARRAY[[1]] AND b=:name ]]`:   `SELECT ARRAY [[1,2],[3,4]] FROM foo  AND a = ARRAY[[1]] AND b=$1 `,
+		`SELECT a[[1]] FROM foo WHERE s = '[[x]]' AND t LIKE '%[[ %' AND name=:name`: `SELECT a[[1]] FROM foo WHERE s = '[[x]]' AND t LIKE '%[[ %' AND name=$1`,
+		`SELECT * FROM foo WHERE s = 'it''s [[ a ]]' [[ AND status=:status ]]`:       `SELECT * FROM foo WHERE s = 'it''s [[ a ]]' `,
+		`SELECT * FROM foo WHERE ([[ status=:status OR ]] name=:name)`:               `SELECT * FROM foo WHERE ( name=$1)`,
+		`SELECT * FROM foo WHERE 1=1 [[ AND a='x]]' AND b=:b ]] AND name=:name`:      `SELECT * FROM foo WHERE 1=1  AND name=$1`,
+		"SELECT * FROM foo /* don't */ WHERE 1=1 [[ AND a=:a ]] AND name=:name":      "SELECT * FROM foo /* don't */ WHERE 1=1  AND name=$1",
+		"SELECT * FROM foo -- don't\nWHERE 1=1 [[ AND a=:a ]] AND name=:name":        "SELECT * FROM foo -- don't\nWHERE 1=1  AND name=$1",
+		`SELECT * FROM foo WHERE x='it\'s' [[ AND a=:a ]] AND name=:name`:            `SELECT * FROM foo WHERE x='it\'s'  AND name=$1`,
+	} {
+		s := New(PostgreSQL)
+		if sql, _, err := s.Named(src, map[string]interface{}{"name": "foobar"}); err != nil || sql != expected {
+			t.Errorf("Expected sql for '%s' was '%s' but got '%s' (%v)", src, expected, sql, err)
+		}
+	}
+	for _, src := range []string{
+		`SELECT * FROM foo WHERE 1=1 [[AND a=:a]]`,
+	} {
+		if _, _, err := Named(src, nil); err != ErrInvalidSection {
+			t.Errorf("Named should return ErrInvalidSection for %s, but got %v", src, err)
+		}
+	}
+	if _, _, err := Named("SELECT * FROM foo WHERE 1=1 [[ AND name=:name", nil); err != ErrUnclosedSection {
+		t.Errorf("Named should return ErrUnclosedSection, but got %v", err)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	columns := map[string]string{"name": "f.name", "created": "f.created_at"}
+	tc := []testCase{
+		{
+			src:   `SELECT * FROM foo f WHERE foo=:foo ORDER BY {order_by:f.id}`,
+			opts:  []NamedOption{OrderBy(columns)},
+			mySQL: `SELECT * FROM foo f WHERE foo=? ORDER BY f.id`,
+			pgSQL: `SELECT * FROM foo f WHERE foo=$1 ORDER BY f.id`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo f WHERE foo=:foo ORDER BY {order_by:f.id}`,
+			opts:  []NamedOption{OrderBy(columns, "-created", "", "+name")},
+			mySQL: `SELECT * FROM foo f WHERE foo=? ORDER BY f.created_at DESC, f.name ASC`,
+			pgSQL: `SELECT * FROM foo f WHERE foo=$1 ORDER BY f.created_at DESC, f.name ASC`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo f WHERE foo=:foo ORDER BY {order_by}`,
+			opts:  []NamedOption{OrderBy(columns, "name desc nulls last", "created NULLS FIRST")},
+			mySQL: `SELECT * FROM foo f WHERE foo=? ORDER BY f.name IS NULL, f.name DESC, f.created_at IS NULL DESC, f.created_at ASC`,
+			pgSQL: `SELECT * FROM foo f WHERE foo=$1 ORDER BY f.name DESC NULLS LAST, f.created_at ASC NULLS FIRST`,
+			args:  []interface{}{"foobar"},
+		},
+	}
+	doTest(t, map[string]interface{}{"foo": "foobar"}, tc, "order by")
+
+	for _, key := range []string{"id", "name; DROP TABLE foo", "-name asc", "name nulls", "name desc first"} {
+		if _, _, err := Named("SELECT * FROM foo ORDER BY {order_by}", nil, OrderBy(columns, key)); err == nil {
+			t.Errorf("OrderBy should return an error for sort key %q, but got none", key)
+		}
+	}
+	s := New(MySQL)
+	s.SetRawVariables(false)
+	if _, _, err := s.Named("SELECT * FROM foo ORDER BY {order_by}", nil, OrderBy(columns, "name")); err != nil {
+		t.Errorf("OrderBy should not return an error when raw variables are disabled, but got %s", err)
+	}
+}
+
+func TestFragments(t *testing.T) {
+	s := New(PostgreSQL)
+	s.DefineFragment("cols", "f.id, f.name")
+	s.DefineFragment("filter", "f.name=:name [[ AND f.status=:status ]] AND {>tenant}")
+	s.DefineFragment("tenant", "f.tenant_id=:tenant {comment?}")
+	sql, args, err := s.Named("SELECT {>cols} FROM foo f WHERE f.id IN(:ids) AND {>filter}", map[string]interface{}{"name": "foobar", "ids": []int{1, 2}, "tenant": 42})
+	if err != nil {
+		t.Fatalf("Named should not return an error, but got %s", err)
+	}
+	if expected := "SELECT f.id, f.name FROM foo f WHERE f.id IN($1, $2) AND f.name=$3  AND f.tenant_id=$4 "; sql != expected {
+		t.Errorf("Expected sql was '%s' but got '%s'", expected, sql)
+	}
+	if expected := []interface{}{1, 2, "foobar", 42}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected args were '%v' but got '%v'", expected, args)
+	}
+
+	src := "SELECT {>cols} FROM foo f WHERE {>loop}"
+	if _, _, err := s.Named(src, nil); err == nil || err.Error() != "Unknown fragment loop" {
+		t.Errorf("Named should return an error for unknown fragments, but got %v", err)
+	}
+	s.DefineFragment("loop", "a=:a AND {>loop2}")
+	s.DefineFragment("loop2", "b=:b AND {>loop}")
+	if _, _, err := s.Named(src, nil); err == nil || err.Error() != "Fragment cycle : loop > loop2 > loop" {
+		t.Errorf("Named should return an error for fragment cycles, but got %v", err)
+	}
+	s.DefineFragment("loop2", "b=:b")
+	if sql, _, err := s.Named(src, nil); err != nil || sql != "SELECT f.id, f.name FROM foo f WHERE a=$1 AND b=$2" {
+		t.Errorf("Redefining a fragment should invalidate cached queries, but got %q, %v", sql, err)
+	}
+}
+
+func TestSub(t *testing.T) {
+	type testStruct struct {
+		Name   string   `db:"name"`
+		Groups SubQuery `db:"groups"`
+	}
+	owner := map[string]interface{}{"owner": "bazbar", "kinds": []string{"a", "b"}}
+	sub := Sub("SELECT id FROM groups WHERE owner=:owner AND kind IN(:kinds) AND parent IN(:parents)", owner, ArgData("parents", Sub("SELECT id FROM parents WHERE owner=:owner", owner)))
+	tc := []testCase{
+		{
+			src:   `SELECT * FROM foo WHERE name=:name AND group_id IN(:groups) AND bar=:bar`,
+			opts:  []NamedOption{ArgData("bar", "barbar")},
+			mySQL: `SELECT * FROM foo WHERE name=? AND group_id IN(SELECT id FROM groups WHERE owner=? AND kind IN(?, ?) AND parent IN(SELECT id FROM parents WHERE owner=?)) AND bar=?`,
+			pgSQL: `SELECT * FROM foo WHERE name=$1 AND group_id IN(SELECT id FROM groups WHERE owner=$2 AND kind IN($3, $4) AND parent IN(SELECT id FROM parents WHERE owner=$5)) AND bar=$6`,
+			args:  []interface{}{"foobar", "bazbar", "a", "b", "bazbar", "barbar"},
+		},
+	}
+	doTest(t, map[string]interface{}{"name": "foobar", "groups": sub}, tc, "map/sub")
+	doTest(t, testStruct{Name: "foobar", Groups: sub}, tc, "struct/sub")
+
+	doTest(t, map[string]interface{}{"id": Sub("SELECT id FROM bar WHERE owner=:owner", owner), "name": "foobar"}, []testCase{
+		{
+			src:   `SELECT * FROM foo WHERE ::match`,
+			mySQL: `SELECT * FROM foo WHERE id IN(SELECT id FROM bar WHERE owner=?) AND name=?`,
+			pgSQL: `SELECT * FROM foo WHERE id IN(SELECT id FROM bar WHERE owner=$1) AND name=$2`,
+			args:  []interface{}{"bazbar", "foobar"},
+		},
+	}, "map/sub/match")
+
+	if _, _, err := Named("SELECT * FROM foo WHERE id IN(:sub)", map[string]interface{}{"sub": Sub("SELECT id FROM bar WHERE {>unknown}", nil)}); err == nil {
+		t.Error("Named should return the errors of subqueries, but got none")
+	}
+}
+
+func TestRaw(t *testing.T) {
+	type testStruct struct {
+		Name      string `db:"name"`
+		Status    Raw    `db:"status"`
+		UpdatedAt Raw    `db:"updated_at,omitempty"`
+	}
+	tc := []testCase{
+		{
+			src:   `INSERT INTO foo (::names) VALUES(::values)`,
+			mySQL: `INSERT INTO foo (name, status, updated_at) VALUES(?, DEFAULT, NOW())`,
+			pgSQL: `INSERT INTO foo (name, status, updated_at) VALUES($1, DEFAULT, NOW())`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `UPDATE foo SET ::name=::value WHERE id=:id`,
+			opts:  []NamedOption{ArgData("id", Raw("LAST_INSERT_ID()"))},
+			mySQL: `UPDATE foo SET name=?, status=DEFAULT, updated_at=NOW() WHERE id=LAST_INSERT_ID()`,
+			pgSQL: `UPDATE foo SET name=$1, status=DEFAULT, updated_at=NOW() WHERE id=LAST_INSERT_ID()`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `SELECT * FROM foo WHERE ::match`,
+			mySQL: `SELECT * FROM foo WHERE name=? AND status=DEFAULT AND updated_at=NOW()`,
+			pgSQL: `SELECT * FROM foo WHERE name=$1 AND status=DEFAULT AND updated_at=NOW()`,
+			args:  []interface{}{"foobar"},
+		},
+	}
+	doTest(t, map[string]interface{}{"name": "foobar", "status": Default, "updated_at": Raw("NOW()")}, tc, "map/raw")
+	doTest(t, testStruct{Name: "foobar", Status: Default, UpdatedAt: Raw("NOW()")}, tc, "struct/raw")
+	tc = []testCase{
+		{
+			src:   `INSERT INTO foo (::names) VALUES(::values)`,
+			mySQL: `INSERT INTO foo (name) VALUES(?)`,
+			pgSQL: `INSERT INTO foo (name) VALUES($1)`,
+			args:  []interface{}{"foobar"},
+		},
+		{
+			src:   `UPDATE foo SET ::name=::value`,
+			mySQL: `UPDATE foo SET name=?`,
+			pgSQL: `UPDATE foo SET name=$1`,
+			args:  []interface{}{"foobar"},
+		},
+	}
+	doTest(t, testStruct{Name: "foobar"}, tc, "struct/emptyraw")
+}
+
+func TestMissingAsDefault(t *testing.T) {
+	type testStruct struct {
+		ID     int           `db:"id,omitempty"`
+		Name   *string       `db:"name"`
+		Kind   missingString `db:"kind"`
+		Status string        `db:"status,updateonly"`
+	}
+	tc := []testCase{
+		{
+			src:   `INSERT INTO foo (::names) VALUES(::values)`,
+			opts:  []NamedOption{MissingAsDefault()},
+			mySQL: `INSERT INTO foo (id, kind, name) VALUES(DEFAULT, ?, DEFAULT)`,
+			pgSQL: `INSERT INTO foo (id, kind, name) VALUES(DEFAULT, $1, DEFAULT)`,
+			args:  []interface{}{missingString{val: "bar"}},
+		},
+		{
+			src:   `INSERT INTO foo (::names) VALUES(::values) ON DUPLICATE KEY UPDATE ::name=::value`,
+			opts:  []NamedOption{MissingAsDefault(), ForInsert()},
+			mySQL: `INSERT INTO foo (id, kind, name) VALUES(DEFAULT, ?, DEFAULT) ON DUPLICATE KEY UPDATE kind=?`,
+			pgSQL: `INSERT INTO foo (id, kind, name) VALUES(DEFAULT, $1, DEFAULT) ON DUPLICATE KEY UPDATE kind=$2`,
+			args:  []interface{}{missingString{val: "bar"}, missingString{val: "bar"}},
+		},
+		{
+			src:   `INSERT INTO foo (::names) VALUES(::values)`,
+			mySQL: `INSERT INTO foo (kind) VALUES(?)`,
+			pgSQL: `INSERT INTO foo (kind) VALUES($1)`,
+			args:  []interface{}{missingString{val: "bar"}},
+		},
+		{
+			src:   `INSERT INTO foo (::names) VALUES(::values)`,
+			opts:  []NamedOption{Only("id", "name")},
+			mySQL: `INSERT INTO foo (id, name) VALUES(?, ?)`,
+			pgSQL: `INSERT INTO foo (id, name) VALUES($1, $2)`,
+			args:  []interface{}{0, (*string)(nil)},
+		},
+	}
+	doTest(t, testStruct{Kind: missingString{val: "bar"}}, tc, "struct/missing as default")
+
+	name := "foobar"
+	tc = []testCase{
+		{
+			src:   `INSERT INTO foo (::names) VALUES(::values)`,
+			opts:  []NamedOption{MissingAsDefault(), Exclude("id")},
+			mySQL: `INSERT INTO foo (kind, name) VALUES(DEFAULT, ?)`,
+			pgSQL: `INSERT INTO foo (kind, name) VALUES(DEFAULT, $1)`,
+			args:  []interface{}{&name},
+		},
+	}
+	doTest(t, testStruct{ID: 42, Name: &name, Kind: missingString{missing: true}}, tc, "struct/missing as default/exclude")
+}
+
 func TestErrors(t *testing.T) {
 	_, _, err := Named("{var}", map[string]interface{}{}, Variables("var"))
 	if err == nil {
@@ -547,7 +1707,7 @@ func TestErrors(t *testing.T) {
 	}
 	_, _, err = Named("foo", nil)
 	if err != nil {
-		t.Error("Calling Named with a nil arg should not generate an error, but got %s", err)
+		t.Errorf("Calling Named with a nil arg should not generate an error, but got %s", err)
 	}
 }
 
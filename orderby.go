@@ -0,0 +1,80 @@
+package sqlbind
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OrderBy sets the {order_by} variable from sort keys, each key being mapped to a column expression by columns.
+// Keys not found in columns are rejected. A key can be prefixed by - (descending) or + (ascending), or followed by
+// ASC or DESC, and by NULLS FIRST or NULLS LAST (emulated using IS NULL for MySQL).
+// If keys is empty, the variable is left unresolved, and its default value is used.
+//
+// OrderBy can be used when raw variables are disabled.
+//
+//  columns := map[string]string{"name": "u.name", "created": "u.created_at"}
+//  sqlbind.Named("SELECT * FROM users u ORDER BY {order_by:u.id}", arg, sqlbind.OrderBy(columns, "-created", "name nulls last"))
+//  // MySQL : ORDER BY u.created_at DESC, u.name IS NULL, u.name ASC
+//  // PostgreSQL : ORDER BY u.created_at DESC, u.name ASC NULLS LAST
+func OrderBy(columns map[string]string, keys ...string) NamedOption {
+	return func(e *context) error {
+		terms := make([]string, 0, len(keys))
+		for _, key := range keys {
+			term, err := e.binder.orderTerm(columns, key)
+			if err != nil {
+				return err
+			}
+			if term != "" {
+				terms = append(terms, term)
+			}
+		}
+		if len(terms) > 0 {
+			setVariables(e, map[string]string{"order_by": strings.Join(terms, ", ")})
+		}
+		return nil
+	}
+}
+
+// orderTerm converts a sort key to an ORDER BY term. Empty keys are ignored.
+func (s *SQLBinder) orderTerm(columns map[string]string, key string) (string, error) {
+	fields := strings.Fields(key)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	name, dir, nulls := fields[0], "ASC", ""
+	switch name[0] {
+	case '-':
+		name, dir = name[1:], "DESC"
+	case '+':
+		name = name[1:]
+	}
+	column, found := columns[name]
+	if !found {
+		return "", fmt.Errorf("Invalid sort key %q", key)
+	}
+	opts := strings.ToUpper(strings.Join(fields[1:], " "))
+	if opts == "ASC" || opts == "DESC" || strings.HasPrefix(opts, "ASC ") || strings.HasPrefix(opts, "DESC ") {
+		if dir == "DESC" && !strings.HasPrefix(opts, "DESC") {
+			return "", fmt.Errorf("Invalid sort key %q", key)
+		}
+		dir = strings.Fields(opts)[0]
+		opts = strings.TrimSpace(opts[len(dir):])
+	}
+	switch opts {
+	case "":
+	case "NULLS FIRST", "NULLS LAST":
+		nulls = opts
+	default:
+		return "", fmt.Errorf("Invalid sort key %q", key)
+	}
+	switch {
+	case nulls == "":
+		return column + " " + dir, nil
+	case s.style == PostgreSQL:
+		return column + " " + dir + " " + nulls, nil
+	case nulls == "NULLS FIRST":
+		return column + " IS NULL DESC, " + column + " " + dir, nil
+	default:
+		return column + " IS NULL, " + column + " " + dir, nil
+	}
+}
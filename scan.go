@@ -1,8 +1,11 @@
 package sqlbind
 
-import "database/sql"
+import (
+	"database/sql"
+	"reflect"
+)
 
-// Scan maps the columns of the current row of a sql.Rows result to a struct
+// Scan maps the columns of the current row of a sql.Rows result to a struct, using the default binder
 //
 //  type Example struct {
 //		ID   int    `db:"id,omit"`
@@ -16,16 +19,26 @@ import "database/sql"
 //	    err = sqlbind.Scan(rows, &e)
 //	}
 func Scan(rows *sql.Rows, arg interface{}) error {
+	return defaultBinder.Scan(rows, arg)
+}
+
+// Scan maps the columns of the current row of a sql.Rows result to a struct, using the specified binder
+func (s *SQLBinder) Scan(rows *sql.Rows, arg interface{}) error {
 	if rows.Err() != nil {
 		return rows.Err()
 	}
+	if v := reflect.Indirect(reflect.ValueOf(arg)); v.Kind() == reflect.Struct {
+		if _, err := s.typeIndexes(v.Type()); err != nil {
+			return err
+		}
+	}
 	names, err := rows.Columns()
 	if err != nil {
 		return err
 	}
 	vals := make([]interface{}, len(names))
 	for i, name := range names {
-		ptr, err := pointerto(name, arg)
+		ptr, err := s.scanTarget(name, arg)
 		if err != nil && err != ErrFieldNotFound {
 			return err
 		}
@@ -38,6 +51,21 @@ func Scan(rows *sql.Rows, arg interface{}) error {
 	return rows.Scan(vals...)
 }
 
+// scanTarget returns a pointer to the field mapped to a column, or a sql.Scanner decoding to the field if it has a codec or a converter
+func (s *SQLBinder) scanTarget(name string, arg interface{}) (interface{}, error) {
+	ptr, err := s.pointerto(name, arg)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := s.indexes(reflect.Indirect(reflect.ValueOf(arg)).Type())[name].opts.codec(); ok && c.dec != nil {
+		return codecScanner{dec: c.dec, dst: ptr}, nil
+	}
+	if dec, ok := s.converter(reflect.TypeOf(ptr).Elem()); ok {
+		return codecScanner{dec: dec, dst: ptr}, nil
+	}
+	return ptr, nil
+}
+
 // ScanRow maps the columns of the first row of a sql.Rows result either to a struct, and closes the rows.
 //
 // sql.QueryRow does not expose column names, therefore ScanRow uses sql.Rows instead of sql.Row.
@@ -45,6 +73,11 @@ func Scan(rows *sql.Rows, arg interface{}) error {
 // 	rows, err := db.Query("SELECT * FROM example")
 // 	err := sqlbind.ScanRow(rows, &e)
 func ScanRow(rows *sql.Rows, arg interface{}) error {
+	return defaultBinder.ScanRow(rows, arg)
+}
+
+// ScanRow maps the columns of the first row of a sql.Rows result to a struct using the specified binder, and closes the rows.
+func (s *SQLBinder) ScanRow(rows *sql.Rows, arg interface{}) error {
 	defer rows.Close()
 	if rows.Err() != nil {
 		return rows.Err()
@@ -52,5 +85,5 @@ func ScanRow(rows *sql.Rows, arg interface{}) error {
 	if !rows.Next() {
 		return sql.ErrNoRows
 	}
-	return Scan(rows, arg)
+	return s.Scan(rows, arg)
 }
@@ -3,8 +3,11 @@ package sqlbind
 import (
 	"database/sql"
 	"database/sql/driver"
+	"net"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/erikstmartin/go-testdb"
 )
@@ -147,6 +150,114 @@ func TestScanEmbeddedPointer(t *testing.T) {
 	}
 }
 
+func TestScanPrefix(t *testing.T) {
+	defer testdb.Reset()
+
+	testdb.SetQueryFunc(func(query string) (result driver.Rows, err error) {
+		columns := []string{"billing_foo", "shipping_foo", "baz"}
+		rows := [][]driver.Value{
+			[]driver.Value{"foobar", "barbar", 42},
+		}
+		return testdb.RowsFromSlice(columns, rows), nil
+	})
+
+	db, _ := sql.Open("testdb", "")
+	rows, _ := db.Query("SELECT foo FROM bar")
+	type sub struct {
+		Foo string `db:"foo"`
+	}
+	type testStruct struct {
+		Billing  sub `db:",prefix=billing_"`
+		Shipping sub `db:",prefix=shipping_"`
+		Baz      int `db:"baz"`
+	}
+	ts := testStruct{}
+	rows.Next()
+	err := Scan(rows, &ts)
+	if err != nil {
+		t.Errorf("ScanRow returned an error : %s", err)
+	} else {
+		ref := testStruct{Billing: sub{Foo: "foobar"}, Shipping: sub{Foo: "barbar"}, Baz: 42}
+		if ts != ref {
+			t.Errorf("ScanRow returned %v, %v expected", ts, ref)
+		}
+	}
+}
+
+func TestScanCodecs(t *testing.T) {
+	defer testdb.Reset()
+
+	testdb.SetQueryFunc(func(query string) (result driver.Rows, err error) {
+		columns := []string{"meta", "ip", "age", "nil", "name"}
+		rows := [][]driver.Value{
+			[]driver.Value{`{"foo":"bar"}`, []byte("127.0.0.1"), "42", nil, "foo"},
+		}
+		return testdb.RowsFromSlice(columns, rows), nil
+	})
+
+	db, _ := sql.Open("testdb", "")
+	rows, _ := db.Query("SELECT foo FROM bar")
+
+	// encode-only codecs are ignored by Scan
+	RegisterCodec("scanupper", func(v interface{}) (interface{}, error) {
+		return strings.ToUpper(v.(string)), nil
+	}, nil)
+	type testStruct struct {
+		Meta map[string]string `db:"meta,json"`
+		IP   net.IP            `db:"ip,text"`
+		Age  int               `db:"age,string"`
+		Nil  map[string]string `db:"nil,json"`
+		Name string            `db:"name,scanupper"`
+	}
+	ts := testStruct{Nil: map[string]string{"foo": "bar"}}
+	rows.Next()
+	err := Scan(rows, &ts)
+	if err != nil {
+		t.Errorf("ScanRow returned an error : %s", err)
+	} else {
+		ref := testStruct{Meta: map[string]string{"foo": "bar"}, IP: net.IPv4(127, 0, 0, 1), Age: 42, Name: "foo"}
+		if !reflect.DeepEqual(ts, ref) {
+			t.Errorf("ScanRow returned %v, %v expected", ts, ref)
+		}
+	}
+}
+
+func TestScanConverters(t *testing.T) {
+	defer testdb.Reset()
+
+	testdb.SetQueryFunc(func(query string) (result driver.Rows, err error) {
+		columns := []string{"timeout", "foo"}
+		rows := [][]driver.Value{
+			[]driver.Value{"1m30s", "foobar"},
+		}
+		return testdb.RowsFromSlice(columns, rows), nil
+	})
+
+	db, _ := sql.Open("testdb", "")
+	rows, _ := db.Query("SELECT foo FROM bar")
+
+	type testStruct struct {
+		Timeout time.Duration `db:"timeout"`
+		Foo     string        `db:"foo"`
+	}
+	s := New(MySQL)
+	s.RegisterConverter(reflect.TypeOf(time.Duration(0)), nil, func(src interface{}, dst interface{}) error {
+		d, err := time.ParseDuration(src.(string))
+		*dst.(*time.Duration) = d
+		return err
+	})
+	ts := testStruct{}
+	err := s.ScanRow(rows, &ts)
+	if err != nil {
+		t.Errorf("ScanRow returned an error : %s", err)
+	} else {
+		ref := testStruct{Timeout: 90 * time.Second, Foo: "foobar"}
+		if ts != ref {
+			t.Errorf("ScanRow returned %v, %v expected", ts, ref)
+		}
+	}
+}
+
 func TestScanMissing(t *testing.T) {
 	defer testdb.Reset()
 
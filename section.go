@@ -0,0 +1,70 @@
+package sqlbind
+
+import (
+	"errors"
+	"reflect"
+)
+
+var (
+	// ErrUnclosedSection is returned when a conditional section ([[ ... ]]) is not closed
+	ErrUnclosedSection = errors.New("Unclosed conditional section")
+	// ErrInvalidSection is returned when placeholders are found between [[ and ]] that are not section delimiters,
+	// e.g. [[AND a=:a]] (sections start with [[ followed by a whitespace)
+	ErrInvalidSection = errors.New("Placeholders between [[ and ]] outside of a conditional section")
+)
+
+// resolveSections removes the conditional sections having a placeholder without a value, and the section delimiters.
+// Only the placeholders of a section are checked, not the ones of its nested sections. Nested sections are removed
+// with their enclosing section.
+func (s *SQLBinder) resolveSections(e *context) error {
+	if !e.decoded.hasType(typeSectionStart) {
+		return nil
+	}
+	type section struct {
+		start int
+		keep  bool
+	}
+	n := make([]part, 0, len(e.parts))
+	stack := []section{}
+	for _, p := range e.parts {
+		switch p.t {
+		case typeSectionStart:
+			stack = append(stack, section{start: len(n), keep: true})
+		case typeSectionEnd:
+			sec := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !sec.keep {
+				n = n[:sec.start]
+			}
+		case typePlaceholder:
+			if l := len(stack); l > 0 && stack[l-1].keep && !s.hasValue(p.data, e) {
+				stack[l-1].keep = false
+			}
+			n = append(n, p)
+		default:
+			n = append(n, p)
+		}
+	}
+	if len(stack) > 0 {
+		return ErrUnclosedSection
+	}
+	e.parts = n
+	return nil
+}
+
+// hasValue returns false if a placeholder has no value : not found, missing (Missinger), nil, nil pointer or empty slice.
+// Values that cannot be encoded are considered present, Named will return the error.
+func (s *SQLBinder) hasValue(name string, e *context) bool {
+	val, found, err := s.value(name, e.arg, e.args...)
+	if err != nil {
+		return true
+	}
+	if !found || isNull(val) {
+		return false
+	}
+	if m, ok := val.(Missinger); ok && m.Missing() {
+		return false
+	}
+	rval := reflect.ValueOf(val)
+	return !shouldExpandSlice(rval) || rval.Len() > 0
+}
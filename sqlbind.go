@@ -9,10 +9,27 @@
 // Automatic IN clause expansion :
 //   sqlbind.Named("SELECT * FROM example WHERE name IN(:name)", map[string]interface{}{"name":[]string{"foo", "bar"}})
 //
+// Empty slices write nothing by default (IN() is invalid SQL). sqlbind.SetEmptySlice and sqlbind.OnEmptySlice can be used
+// to return an error, write NULL or rewrite the predicate to a constant false :
+//   sqlbind.Named("SELECT * FROM example WHERE name IN(:name)", arg, sqlbind.OnEmptySlice(sqlbind.EmptySliceFalse)) // WHERE 1=0
+//
+// Slices of structs and of []interface{} are expanded to tuples, struct fields being bound in the order of the columns preceding IN :
+//   sqlbind.Named("SELECT * FROM example WHERE (tenant_id, id) IN(:keys)", map[string]interface{}{"keys": keys}) // (tenant_id, id) IN((?, ?), (?, ?))
+//
+// With PostgreSQL, SetInMode(sqlbind.InAny, encoder) binds slices as a single array parameter, IN($1) being rewritten to = ANY($1).
+// Empty slices are bound as empty arrays, only the EmptySliceError policy applies.
+//
+// Byte slices and slices implementing driver.Valuer are not expanded, sqlbind.NoExpand(v) binds any other slice as a single value.
+//
+// sqlbind.Raw values (e.g. sqlbind.Raw("NOW()") or sqlbind.Default) are written verbatim instead of a placeholder.
+//
 // Variable args :
 //   sqlbind.Named("INSERT INTO example (::names) VALUES(::values)", map[string]interface{}{"name":"foo"}'})
 //   sqlbind.Named("UPDATE example SET ::name=::value", map[string]interface{}{"name":"foo"}'})
 //
+// Exact-match lookups, joining with AND and using IS NULL for nil values :
+//   sqlbind.Named("SELECT * FROM example WHERE ::match", map[string]interface{}{"name":"foo", "domain": nil})
+//
 // Structs, using tags to define DB field names :
 //   type Example struct {
 //   	Name string `db:"name"`
@@ -20,9 +37,15 @@
 //   e := Example{Name: "foo"}
 //   sqlbind.Named("SELECT * FROM example WHERE name=:name", e)
 //
+// Any map with string keys, url.Values, []sql.NamedArg or sqlbind.ArgSource (e.g. sqlbind.Values(url.Values), sqlbind.NamedArgs(...sql.NamedArg)) can also be used :
+//   sqlbind.Named("SELECT * FROM example WHERE name=:name AND id IN(:id)", sqlbind.Values(r.URL.Query()))
+//
 // Add args to a struct (e.g. from query string parameters) :
 //   sqlbind.Named("SELECT * FROM example WHERE name=:name AND domain=:domain", e, sqlbind.Args("domain", "example.com"))
 //
+// Placeholders without a value are bound to NULL, unless using strict mode (sqlbind.SetStrict(true) or sqlbind.Strict()),
+// where Named returns an error listing all placeholders without a value.
+//
 // Named placeholders are automatically translated to the right driver-dependant placeholder : ? for MySQL (default style)
 //   sqlbind.SetStyle(sqlbind.MySQL)
 // or $N for PostgreSQL
@@ -38,13 +61,85 @@
 //   	ID int `db:"id,omit"` // will not be expanded by ::names and ::name=::value
 //   }
 //
+// Zero values can be skipped using omitempty (or its alias omitzero), types implementing `IsZero() bool` define their own zero value :
+//   type Example struct {
+//   	Name string `db:"name,omitempty"` // will not be expanded when empty
+//   }
+//
+// Fields can be restricted to inserts (::values) or updates (::name=::value), and tags can have several options :
+//   type Example struct {
+//   	CreatedAt time.Time `db:"created_at,insertonly"`
+//   	UpdatedBy string    `db:"updated_by,updateonly,omitempty"`
+//   }
+// sqlbind.ForInsert() and sqlbind.ForUpdate() set the statement kind when it cannot be guessed from the query.
+//
+// Fields can belong to groups, used by sqlbind.OnlyGroups and sqlbind.ExcludeGroups :
+//   type Example struct {
+//   	Street string `db:"street,group=address"`
+//   }
+//   sqlbind.Named("UPDATE example SET ::name=::value", e, sqlbind.OnlyGroups("address"))
+//
+// sqlbind.MissingAsDefault() keeps missing fields in ::names and ::values, DEFAULT being used as their value.
+//
+// Untagged struct fields are inlined, except time.Time, driver.Valuer and sql.Scanner types (embedded structs are always inlined). A prefix option prefixes the names of their fields, both for named parameters and Scan :
+//   type Example struct {
+//   	Billing  Address `db:",prefix=billing_"`
+//   	Shipping Address `db:",prefix=shipping_"`
+//   }
+//
+// When several fields have the same name, the shallowest one is used. Register, Named and Scan return an error for fields having the same name at the same depth.
+//
+// Fields without a db tag name use the field name verbatim, unless a name mapper is set :
+//   sqlbind.SetNameMapper(sqlbind.SnakeCase) // CreatedAt -> created_at
+//
+// Primary keys
+//
+// Fields tagged pk are not expanded by ::name=::value, unless listed with sqlbind.Only. ::pk expands to a condition on all pk fields, in declaration order :
+//   type Example struct {
+//   	TenantID int    `db:"tenant_id,pk"`
+//   	ID       int    `db:"id,pk"`
+//   	Name     string `db:"name"`
+//   }
+//   sqlbind.Named("UPDATE example SET ::name=::value WHERE ::pk", e) // UPDATE example SET name=? WHERE tenant_id=? AND id=?
+//
 // Variables
 //
 // Additional variables can be added to SQL queries :
 //   sqlbind.Named("SELECT /* {comment} */ * FROM {table_prefix}example WHERE name=:name", e, sqlbind.Variables("comment", "foo", "table_prefix", "bar_"))
 //
+// Variables can also be set using sqlbind.VariablesMap or sqlbind.VariablesStruct. Variables without a value are replaced by their default value :
+//   sqlbind.Named("SELECT * FROM {table_prefix:app_}example", e) // SELECT * FROM app_example
+//
+// In strict variables mode (sqlbind.SetStrictVariables or sqlbind.StrictVariables), Named returns an error for variables without a value,
+// unless they have a default value or are marked as optional ({name?}).
+//
 // Braces inside quotes are ignored : "{value}" will not be modified.
 //
+// Variables are inserted verbatim. Identifiers from user input must use sqlbind.Identifier, which validates and quotes them :
+//   sqlbind.Named("SELECT * FROM {table}", e, sqlbind.Identifier("table", r.FormValue("type"), "users", "groups"))
+// Sort keys can be mapped to allowed column expressions using sqlbind.OrderBy, which fills the {order_by} variable :
+//   sqlbind.Named("SELECT * FROM example e ORDER BY {order_by:e.id}", e, sqlbind.OrderBy(map[string]string{"name": "e.name"}, "-name"))
+// sqlbind.SetRawVariables(false) disables sqlbind.Variables, sqlbind.VariablesMap and sqlbind.VariablesStruct.
+//
+// Conditional sections
+//
+// Sections between [[ and ]] are only written if all their placeholders have a value (not nil, not missing, not an empty slice) :
+//   sqlbind.Named("SELECT * FROM example WHERE 1=1 [[ AND name=:name ]] [[ AND id IN(:ids) ]]", e)
+//
+// [[ only opens a section when surrounded by whitespaces (or preceded by "(" or another section), outside single-quoted literals
+// and comments. Placeholders between [[ and ]] that do not delimit a section return sqlbind.ErrInvalidSection.
+//
+// Subqueries
+//
+// Queries created with sqlbind.Sub can be bound as parameter values, their placeholders are renumbered :
+//   sqlbind.Named("SELECT * FROM example WHERE group_id IN(:groups)", map[string]interface{}{"groups": sqlbind.Sub("SELECT id FROM groups WHERE owner=:owner", o)})
+//
+// Fragments
+//
+// Named SQL fragments, that can contain placeholders, are included in queries using {>name} :
+//   sqlbind.DefineFragment("example_cols", "e.id, e.name")
+//   sqlbind.Named("SELECT {>example_cols} FROM example e WHERE e.name=:name", e)
+//
 // JSON and missing fields
 //
 // In a REST API, PATCH update calls may update only certain fields. When using structs with plain types, it is impossible to differentiate between empty fields {"name":""}, null fields : {"name": null} and missing fields : {}.
@@ -89,10 +184,23 @@
 //
 // Slices of structs are not mapped, only structs.
 //
+// Codecs
+//
+// Fields can be encoded when binding and decoded by Scan using json, text (encoding.TextMarshaler/TextUnmarshaler) or string codecs :
+//   type Example struct {
+//   	Meta map[string]string `db:"meta,json"`
+//   	Age  int               `db:"age,string"`
+//   }
+// Custom codecs can be added using sqlbind.RegisterCodec.
+//
+// Types not implementing driver.Valuer/sql.Scanner can also be converted by a binder, using RegisterConverter :
+//   sqlbind.RegisterConverter(reflect.TypeOf(uuid.UUID{}), toDB, fromDB)
+//
 // Instances
 //
 // You can build a SQLBinder instance :
 //   s := sqlbind.New(sqlbind.MySQL)
+//   s.SetNameMapper(sqlbind.SnakeCase)
 //   s.Register(Example{}, Foo{})
 //   s.Named("SELECT * FROM example WHERE name=:name", e)
 package sqlbind
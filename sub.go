@@ -0,0 +1,34 @@
+package sqlbind
+
+import "bytes"
+
+// SubQuery is a query bound as a parameter value. See Sub.
+type SubQuery struct {
+	sql  string
+	arg  interface{}
+	opts []NamedOption
+}
+
+// Sub creates a subquery, that can be used as a parameter value. The subquery is inlined, its placeholders are
+// numbered with the ones of the enclosing query, and its args are inserted in order.
+//
+//  sub := sqlbind.Sub("SELECT id FROM groups WHERE owner=:owner", arg)
+//  sqlbind.Named("SELECT * FROM users WHERE name=:name AND group_id IN(:groups)", map[string]interface{}{"name": "foo", "groups": sub})
+//  // PostgreSQL : SELECT * FROM users WHERE name=$1 AND group_id IN(SELECT id FROM groups WHERE owner=$2)
+func Sub(sql string, arg interface{}, opts ...NamedOption) SubQuery {
+	return SubQuery{sql: sql, arg: arg, opts: opts}
+}
+
+func isSubQuery(v interface{}) bool {
+	_, ok := v.(SubQuery)
+	return ok
+}
+
+// writeSub writes a subquery, numbering placeholders from i
+func (s *SQLBinder) writeSub(sql *bytes.Buffer, sub SubQuery, i int) (int, []interface{}, error) {
+	c, err := s.decode(sub.sql)
+	if err != nil {
+		return i, nil, err
+	}
+	return s.write(sql, c, i, sub.arg, sub.opts...)
+}
@@ -0,0 +1,179 @@
+package sqlbind
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrRawVariables = errors.New("Raw variables are disabled, use Identifier instead")
+
+	validIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)*$`)
+)
+
+// VariablesMap sets variable values from a map. See Variables.
+//
+//  sqlbind.Named("SELECT * FROM {table_prefix}example", args, sqlbind.VariablesMap(map[string]string{"table_prefix": "foo_"}))
+func VariablesMap(v map[string]string) NamedOption {
+	return func(e *context) error {
+		if e.binder.noRawVariables {
+			return ErrRawVariables
+		}
+		setVariables(e, v)
+		return nil
+	}
+}
+
+// VariablesStruct sets variable values from the string fields of a struct, named like parameters (db tags). See Variables.
+//
+// 	type vars struct {
+// 		TablePrefix string `db:"table_prefix"`
+// 	}
+//  sqlbind.Named("SELECT * FROM {table_prefix}example", args, sqlbind.VariablesStruct(vars{TablePrefix: "foo_"}))
+func VariablesStruct(arg interface{}) NamedOption {
+	return func(e *context) error {
+		if e.binder.noRawVariables {
+			return ErrRawVariables
+		}
+		v := reflect.Indirect(reflect.ValueOf(arg))
+		if !v.IsValid() || v.Kind() != reflect.Struct {
+			return ErrUnsupportedFormat
+		}
+		is, err := e.binder.typeIndexes(v.Type())
+		if err != nil {
+			return err
+		}
+		vars := make(map[string]string, len(is))
+		for name := range is {
+			fv, found := e.binder.field(name, v)
+			if !found {
+				continue
+			}
+			if fv.Kind() != reflect.String {
+				return fmt.Errorf("Variable %s must be a string, not %s", name, fv.Type())
+			}
+			vars[name] = fv.String()
+		}
+		setVariables(e, vars)
+		return nil
+	}
+}
+
+// SetStrictVariables sets the strict variables mode of the default binder
+func SetStrictVariables(strict bool) {
+	defaultBinder.strictVars = strict
+}
+
+// SetStrictVariables sets the strict variables mode of the binder. In strict variables mode, Named returns an error
+// if a variable has no value. Variables with a default value ({name:default}) or marked as optional ({name?}) are allowed.
+func (s *SQLBinder) SetStrictVariables(strict bool) {
+	s.strictVars = strict
+}
+
+// StrictVariables makes Named return an error if a variable has no value and is neither optional ({name?})
+// nor has a default value ({name:default}).
+//
+//  sqlbind.Named("SELECT /* {comment?} */ * FROM {schema}.users", arg, sqlbind.StrictVariables()) // No value for variables : schema
+func StrictVariables() NamedOption {
+	return func(e *context) error {
+		e.strictVars = true
+		return nil
+	}
+}
+
+// SetRawVariables enables or disables raw variables (see Variables) in the default binder
+func SetRawVariables(allowed bool) {
+	defaultBinder.SetRawVariables(allowed)
+}
+
+// SetRawVariables enables (default) or disables raw variables in the binder. When disabled, Variables returns
+// ErrRawVariables, and only validated variables (e.g. Identifier) can be used.
+func (s *SQLBinder) SetRawVariables(allowed bool) {
+	s.noRawVariables = !allowed
+}
+
+// Identifier sets a variable to a SQL identifier (e.g. a table or column name), quoted according to the binder style :
+// `name` for MySQL, "name" for PostgreSQL. Qualified identifiers (schema.table) are allowed.
+//
+// If allowed is not empty, the value must be one of the allowed values, otherwise it must be a valid identifier
+// (letters, digits, _ and $, not starting with a digit).
+//
+//  sqlbind.Named("SELECT * FROM {table} WHERE id=:id", arg, sqlbind.Identifier("table", r.FormValue("type"), "users", "groups"))
+func Identifier(name, value string, allowed ...string) NamedOption {
+	return func(e *context) error {
+		quoted, err := e.binder.quoteIdentifier(name, value, allowed)
+		if err != nil {
+			return err
+		}
+		setVariables(e, map[string]string{name: quoted})
+		return nil
+	}
+}
+
+// IdentifierVariables sets several identifier variables, without allowlists. See Identifier.
+//
+//  sqlbind.Named("SELECT * FROM {schema}.{table}", arg, sqlbind.IdentifierVariables("schema", "app", "table", "users"))
+func IdentifierVariables(vars ...string) NamedOption {
+	if len(vars)%2 != 0 {
+		return errorOption(errors.New("IdentifierVariables() must have a multiple of 2 args"))
+	}
+	return func(e *context) error {
+		v := map[string]string{}
+		for i := 0; i < len(vars); i += 2 {
+			quoted, err := e.binder.quoteIdentifier(vars[i], vars[i+1], nil)
+			if err != nil {
+				return err
+			}
+			v[vars[i]] = quoted
+		}
+		setVariables(e, v)
+		return nil
+	}
+}
+
+func (s *SQLBinder) quoteIdentifier(name, value string, allowed []string) (string, error) {
+	if len(allowed) > 0 {
+		found := false
+		for _, a := range allowed {
+			if a == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("Identifier %q is not allowed for {%s}", value, name)
+		}
+	} else if !validIdentifier.MatchString(value) {
+		return "", fmt.Errorf("Invalid identifier %q for {%s}", value, name)
+	}
+	q := "`"
+	if s.style == PostgreSQL {
+		q = `"`
+	}
+	parts := strings.Split(value, ".")
+	for i, part := range parts {
+		parts[i] = q + strings.Replace(part, q, q+q, -1) + q
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// setVariables replaces variables with their values
+func setVariables(e *context, v map[string]string) {
+	if !e.decoded.hasType(typeVariable) {
+		return
+	}
+	n := make([]part, 0, len(e.parts))
+	for _, p := range e.parts {
+		if p.t == typeVariable {
+			if val, ok := v[p.data]; ok {
+				n = append(n, part{t: typeSQL, data: val})
+				continue
+			}
+		}
+		n = append(n, p)
+	}
+	e.parts = n
+}